@@ -0,0 +1,110 @@
+package morgana
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+)
+
+// Annotation is one wrap layer recorded by Wrapf, Annotate or WithAnnotation:
+// the message given at the wrap site, the frame that wrapped and optional
+// metadata describing that layer.
+type Annotation struct {
+	Msg      string         `json:"msg"`
+	Frame    StackFrame     `json:"frame"`
+	MetaData map[string]any `json:"metaData,omitempty"`
+}
+
+// WithAnnotation appends a wrap layer with the frame selected by skip, counted
+// like WithStackTrace. Type, codes and message are left untouched.
+func (m *morgana) WithAnnotation(skip int, msg string, metaData map[string]any) Morgana {
+	c := m.edit()
+	if m.immutable {
+		c.Annotations = slices.Clip(c.Annotations)
+	}
+	c.Annotations = append(c.Annotations, Annotation{Msg: msg, Frame: callerFrame(skip), MetaData: metaData})
+	return c
+}
+
+// GetAnnotations returns the wrap layers, innermost first. For an immutable
+// Morgana the slice and the metadata of each layer are copies.
+func (m *morgana) GetAnnotations() []Annotation {
+	if m.immutable {
+		return copyAnnotations(m.Annotations)
+	}
+	return m.Annotations
+}
+
+// Wrapf records a wrap layer on err at the caller's line:
+//
+//	return morgana.Wrapf(err, "loading user %d", id)
+//
+// The Morgana carried by err, or a GENERAL one built by FromError, is copied
+// and the annotation is appended to the copy, so err itself is never changed.
+// The returned error reads "msg: err" and unwraps to err, so errors.Is and
+// errors.As still see every link of its chain, while GetMorgana yields the
+// annotated copy. Wrapf returns nil when err is nil.
+func Wrapf(err error, format string, args ...any) error {
+	return annotate(err, nil, fmt.Sprintf(format, args...))
+}
+
+// Annotate is Wrapf with metadata attached to the recorded layer.
+func Annotate(err error, metaData map[string]any, format string, args ...any) error {
+	return annotate(err, metaData, fmt.Sprintf(format, args...))
+}
+
+func annotate(err error, metaData map[string]any, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &annotated{
+		m:   FromError(err).DeepClone().WithAnnotation(4, msg, metaData),
+		msg: msg,
+		err: err,
+	}
+}
+
+// annotated is the error returned by Wrapf and Annotate.
+type annotated struct {
+	m   Morgana
+	msg string
+	err error
+}
+
+func (a *annotated) Error() string {
+	return a.msg + ": " + a.err.Error()
+}
+
+func (a *annotated) Unwrap() error {
+	return a.err
+}
+
+// As hands out the annotated copy before errors.As descends into err, which
+// still holds the Morgana without this layer.
+func (a *annotated) As(target any) bool {
+	if t, ok := target.(*Morgana); ok {
+		*t = a.m
+		return true
+	}
+	return false
+}
+
+func callerFrame(skip int) StackFrame {
+	pc := make([]uintptr, 1)
+	if runtime.Callers(skip+1, pc) == 0 {
+		return StackFrame{}
+	}
+	frame, _ := runtime.CallersFrames(pc).Next()
+	return StackFrame{File: frame.File, Line: frame.Line, Function: frame.Function}
+}
+
+func (m *morgana) redactAnnotations() []Annotation {
+	if len(m.Annotations) == 0 {
+		return nil
+	}
+	out := make([]Annotation, len(m.Annotations))
+	for i, a := range m.Annotations {
+		out[i] = Annotation{Msg: a.Msg, Frame: a.Frame, MetaData: m.redactMap(a.MetaData)}
+	}
+	return out
+}
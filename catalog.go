@@ -0,0 +1,309 @@
+package morgana
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Metadata keys set on Morganas created from a catalog entry.
+const (
+	MetaDataSeverity      = "severity"
+	MetaDataRetryable     = "retryable"
+	MetaDataDocsURL       = "docs_url"
+	MetaDataPublicMessage = "public_message"
+)
+
+// CatalogEntry describes one error code. Message and PublicMessage may
+// contain {name} placeholders.
+type CatalogEntry struct {
+	Type          string `json:"type" yaml:"type"`
+	CustomCode    string `json:"customCode" yaml:"customCode"`
+	StatusCode    int    `json:"statusCode" yaml:"statusCode"`
+	Message       string `json:"message" yaml:"message"`
+	Severity      string `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Retryable     bool   `json:"retryable,omitempty" yaml:"retryable,omitempty"`
+	DocsURL       string `json:"docsUrl,omitempty" yaml:"docsUrl,omitempty"`
+	PublicMessage string `json:"publicMessage,omitempty" yaml:"publicMessage,omitempty"`
+	// Params optionally declares the placeholders of Message. When present
+	// they must match the placeholders exactly.
+	Params []CatalogParam `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// CatalogParam declares a message placeholder. Type is the Go type generated
+// constructors accept for it and defaults to string.
+type CatalogParam struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+// Parameters returns the declared Params, or one string parameter per
+// placeholder of Message when none are declared.
+func (e CatalogEntry) Parameters() []CatalogParam {
+	if len(e.Params) != 0 {
+		return e.Params
+	}
+	var params []CatalogParam
+	for _, name := range Placeholders(e.Message) {
+		params = append(params, CatalogParam{Name: name, Type: "string"})
+	}
+	return params
+}
+
+// Validate checks that declared Params match the placeholders of Message and
+// cover those of PublicMessage.
+func (e CatalogEntry) Validate() error {
+	if len(e.Params) == 0 {
+		return nil
+	}
+	declared := make(map[string]struct{}, len(e.Params))
+	for _, p := range e.Params {
+		if _, ok := declared[p.Name]; ok {
+			return fmt.Errorf("morgana: catalog code %q declares param %q twice", e.CustomCode, p.Name)
+		}
+		declared[p.Name] = struct{}{}
+	}
+	used := make(map[string]struct{})
+	for _, name := range Placeholders(e.Message) {
+		if _, ok := declared[name]; !ok {
+			return fmt.Errorf("morgana: catalog code %q uses undeclared placeholder {%s}", e.CustomCode, name)
+		}
+		used[name] = struct{}{}
+	}
+	for _, name := range Placeholders(e.PublicMessage) {
+		if _, ok := declared[name]; !ok {
+			return fmt.Errorf("morgana: catalog code %q uses undeclared placeholder {%s} in publicMessage", e.CustomCode, name)
+		}
+	}
+	for _, p := range e.Params {
+		if _, ok := used[p.Name]; !ok {
+			return fmt.Errorf("morgana: catalog code %q declares param %q missing from its message", e.CustomCode, p.Name)
+		}
+	}
+	return nil
+}
+
+// Definition returns a sentinel Definition for the entry.
+func (e CatalogEntry) Definition() *Definition {
+	return Define(e.Type, e.CustomCode, e.StatusCode, e.Message)
+}
+
+// CatalogFile is the document layout read by LoadJSON, LoadYAML and LoadFile.
+type CatalogFile struct {
+	Errors []CatalogEntry `json:"errors" yaml:"errors"`
+}
+
+// Catalog is a registry of error codes keyed by CustomCode. It is safe for
+// concurrent use.
+type Catalog struct {
+	mu      sync.RWMutex
+	entries map[string]CatalogEntry
+	order   []string
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{entries: make(map[string]CatalogEntry)}
+}
+
+// DefaultCatalog is used by Register, LoadCatalogFile, FromCatalog and FromError.
+var DefaultCatalog = NewCatalog()
+
+// Register adds entries to the catalog. Nothing is added if any entry has an
+// empty CustomCode, a code that is already registered or fails Validate.
+func (c *Catalog) Register(entries ...CatalogEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batch := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		if e.CustomCode == "" {
+			return fmt.Errorf("morgana: catalog entry of type %q has no customCode", e.Type)
+		}
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		_, inBatch := batch[e.CustomCode]
+		if _, ok := c.entries[e.CustomCode]; ok || inBatch {
+			return fmt.Errorf("morgana: duplicate catalog code %q", e.CustomCode)
+		}
+		batch[e.CustomCode] = struct{}{}
+	}
+	for _, e := range entries {
+		c.entries[e.CustomCode] = e
+		c.order = append(c.order, e.CustomCode)
+	}
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func (c *Catalog) MustRegister(entries ...CatalogEntry) {
+	if err := c.Register(entries...); err != nil {
+		panic(err)
+	}
+}
+
+func (c *Catalog) Lookup(code string) (CatalogEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[code]
+	return e, ok
+}
+
+func (c *Catalog) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.order)
+}
+
+// Entries returns every entry in registration order.
+func (c *Catalog) Entries() []CatalogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]CatalogEntry, 0, len(c.order))
+	for _, code := range c.order {
+		out = append(out, c.entries[code])
+	}
+	return out
+}
+
+// All iterates over a snapshot of the entries in registration order.
+func (c *Catalog) All() iter.Seq[CatalogEntry] {
+	entries := c.Entries()
+	return func(yield func(CatalogEntry) bool) {
+		for _, e := range entries {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Catalog) LoadJSON(r io.Reader) error {
+	var f CatalogFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return fmt.Errorf("morgana: decoding catalog JSON: %w", err)
+	}
+	return c.Register(f.Errors...)
+}
+
+func (c *Catalog) LoadYAML(r io.Reader) error {
+	var f CatalogFile
+	if err := yaml.NewDecoder(r).Decode(&f); err != nil && err != io.EOF {
+		return fmt.Errorf("morgana: decoding catalog YAML: %w", err)
+	}
+	return c.Register(f.Errors...)
+}
+
+// LoadFile loads a .json, .yaml or .yml catalog file.
+func (c *Catalog) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return c.LoadJSON(f)
+	case ".yaml", ".yml":
+		return c.LoadYAML(f)
+	default:
+		return fmt.Errorf("morgana: unsupported catalog file extension %q", filepath.Ext(path))
+	}
+}
+
+// New returns a Morgana populated from the entry registered under code, with
+// placeholders filled from params. Unknown codes produce a GENERAL Morgana
+// with status 500 carrying the code.
+func (c *Catalog) New(code string, params map[string]any) Morgana {
+	return c.instance(code, params, 4)
+}
+
+func (c *Catalog) instance(code string, params map[string]any, skip int) Morgana {
+	e, ok := c.Lookup(code)
+	if !ok {
+		return New("GENERAL").WithCustomCode(code).WithStatusCode(http.StatusInternalServerError).
+			WithMessage("unregistered error code").WithStackTrace(skip)
+	}
+	m := New(e.Type).WithCustomCode(e.CustomCode).WithStatusCode(e.StatusCode).
+		WithMessage(renderMessage(e.Message, params)).WithStackTrace(skip)
+	return m.WithAddMetaData(e.metaData(params))
+}
+
+func (e CatalogEntry) metaData(params map[string]any) map[string]any {
+	md := map[string]any{MetaDataRetryable: e.Retryable}
+	if e.Severity != "" {
+		md[MetaDataSeverity] = e.Severity
+	}
+	if e.DocsURL != "" {
+		md[MetaDataDocsURL] = e.DocsURL
+	}
+	if e.PublicMessage != "" {
+		md[MetaDataPublicMessage] = renderMessage(e.PublicMessage, params)
+	}
+	return md
+}
+
+// enrich fills the Type, StatusCode, message and catalog metadata a Morgana
+// carrying only a registered CustomCode is missing. The entry's messages are
+// rendered with the metadata as params; a message whose placeholders the
+// metadata does not all resolve is left out. m is never modified; a copy is
+// returned when anything had to be filled in.
+func (c *Catalog) enrich(m Morgana) Morgana {
+	mm, ok := m.(*morgana)
+	if !ok || mm.CustomCode == "" || (mm.Type != "" && mm.StatusCode != 0 && mm.Msg != "") {
+		return m
+	}
+	e, ok := c.Lookup(mm.CustomCode)
+	if !ok {
+		return m
+	}
+	out := mm.deepCopy(mm.immutable)
+	if out.Type == "" {
+		out.Type = e.Type
+	}
+	if out.StatusCode == 0 {
+		out.StatusCode = e.StatusCode
+	}
+	if out.Msg == "" {
+		if msg := renderMessage(e.Message, out.MetaData); len(Placeholders(msg)) == 0 {
+			out.Msg = msg
+		}
+	}
+	if out.MetaData == nil {
+		out.MetaData = make(map[string]any)
+	}
+	md := e.metaData(out.MetaData)
+	if msg, _ := md[MetaDataPublicMessage].(string); len(Placeholders(msg)) != 0 {
+		delete(md, MetaDataPublicMessage)
+	}
+	for k, v := range md {
+		if _, exists := out.MetaData[k]; !exists {
+			out.MetaData[k] = v
+		}
+	}
+	return out
+}
+
+// Register adds entries to DefaultCatalog.
+func Register(entries ...CatalogEntry) error {
+	return DefaultCatalog.Register(entries...)
+}
+
+// LoadCatalogFile loads a catalog file into DefaultCatalog.
+func LoadCatalogFile(path string) error {
+	return DefaultCatalog.LoadFile(path)
+}
+
+// FromCatalog returns a Morgana built from the DefaultCatalog entry for code.
+func FromCatalog(code string, params map[string]any) Morgana {
+	return DefaultCatalog.instance(code, params, 4)
+}
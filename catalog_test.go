@@ -0,0 +1,117 @@
+package morgana_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const catalogYAML = `
+errors:
+  - type: VALIDATION
+    customCode: INVALID_INPUT
+    statusCode: 400
+    message: "field {field} is invalid"
+    severity: warning
+    docsUrl: https://errors.example.com/INVALID_INPUT
+    publicMessage: "Please check {field}"
+  - type: UPSTREAM
+    customCode: UPSTREAM_DOWN
+    statusCode: 503
+    message: upstream unavailable
+    retryable: true
+`
+
+func TestCatalog(t *testing.T) {
+	t.Run("LoadYAMLAndNew", func(t *testing.T) {
+		c := morgana.NewCatalog()
+		require.NoError(t, c.LoadYAML(strings.NewReader(catalogYAML)))
+		assert.Equal(t, 2, c.Len())
+
+		m := c.New("INVALID_INPUT", map[string]any{"field": "email"})
+		assert.Equal(t, "VALIDATION", m.GetType())
+		assert.Equal(t, http.StatusBadRequest, m.GetStatusCode())
+		assert.Equal(t, "field email is invalid", m.GetMessage())
+		assert.Equal(t, "warning", m.GetMetaDataKey(morgana.MetaDataSeverity))
+		assert.Equal(t, "Please check email", m.GetMetaDataKey(morgana.MetaDataPublicMessage))
+		assert.Equal(t, false, m.GetMetaDataKey(morgana.MetaDataRetryable))
+	})
+
+	t.Run("LoadJSONFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "errors.json")
+		data := `{"errors":[{"type":"AUTH","customCode":"TOKEN_EXPIRED","statusCode":401,"message":"token expired"}]}`
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+		c := morgana.NewCatalog()
+		require.NoError(t, c.LoadFile(path))
+		e, ok := c.Lookup("TOKEN_EXPIRED")
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusUnauthorized, e.StatusCode)
+	})
+
+	t.Run("RejectsDuplicates", func(t *testing.T) {
+		c := morgana.NewCatalog()
+		require.NoError(t, c.Register(morgana.CatalogEntry{Type: "A", CustomCode: "DUP"}))
+		assert.Error(t, c.Register(morgana.CatalogEntry{Type: "B", CustomCode: "DUP"}))
+		assert.Error(t, c.Register(morgana.CatalogEntry{CustomCode: "X"}, morgana.CatalogEntry{CustomCode: "X"}))
+		assert.Error(t, c.Register(morgana.CatalogEntry{Type: "NO_CODE"}))
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("IterationOrder", func(t *testing.T) {
+		c := morgana.NewCatalog()
+		require.NoError(t, c.LoadYAML(strings.NewReader(catalogYAML)))
+		var codes []string
+		for e := range c.All() {
+			codes = append(codes, e.CustomCode)
+		}
+		assert.Equal(t, []string{"INVALID_INPUT", "UPSTREAM_DOWN"}, codes)
+	})
+
+	t.Run("UnknownCode", func(t *testing.T) {
+		m := morgana.NewCatalog().New("MISSING", nil)
+		assert.Equal(t, "MISSING", m.GetCustomCode())
+		assert.Equal(t, http.StatusInternalServerError, m.GetStatusCode())
+	})
+
+	t.Run("FromErrorEnrichesBareCodes", func(t *testing.T) {
+		require.NoError(t, morgana.Register(morgana.CatalogEntry{
+			Type: "QUOTA", CustomCode: "CATALOG_TEST_QUOTA", StatusCode: http.StatusTooManyRequests, Message: "quota exceeded",
+		}))
+
+		bare := morgana.New("").WithCustomCode("CATALOG_TEST_QUOTA")
+		m := morgana.FromError(bare.ToError())
+		assert.Equal(t, "QUOTA", m.GetType())
+		assert.Equal(t, http.StatusTooManyRequests, m.GetStatusCode())
+		assert.Equal(t, "quota exceeded", m.GetMessage())
+		assert.Equal(t, 0, bare.GetStatusCode())
+
+		foreign := morgana.FromError(errors.New("CATALOG_TEST_QUOTA"))
+		assert.Equal(t, http.StatusTooManyRequests, foreign.GetStatusCode())
+		assert.Equal(t, "CATALOG_TEST_QUOTA", foreign.GetCustomCode())
+	})
+
+	t.Run("FromErrorRendersMessages", func(t *testing.T) {
+		require.NoError(t, morgana.Register(morgana.CatalogEntry{
+			Type: "QUOTA", CustomCode: "CATALOG_TEST_LIMIT", StatusCode: http.StatusTooManyRequests,
+			Message: "limit {limit} reached", PublicMessage: "Slow down, {user}",
+		}))
+
+		m := morgana.FromError(morgana.New("").WithCustomCode("CATALOG_TEST_LIMIT").
+			WithAddMetaDataKey("limit", 10).WithAddMetaDataKey("user", "ann"))
+		assert.Equal(t, "limit 10 reached", m.GetMessage())
+		assert.Equal(t, "Slow down, ann", m.GetMetaDataKey(morgana.MetaDataPublicMessage))
+
+		m = morgana.FromError(morgana.New("").WithCustomCode("CATALOG_TEST_LIMIT"))
+		assert.Empty(t, m.GetMessage())
+		assert.False(t, m.HasMetaDataKey(morgana.MetaDataPublicMessage))
+		assert.Equal(t, http.StatusTooManyRequests, m.GetStatusCode())
+	})
+}
@@ -0,0 +1,200 @@
+package morgana
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxErrorBodySize bounds how much of an error response is read.
+	maxErrorBodySize = 1 << 20
+	// maxFallbackMessage bounds the message built from an unrecognised body.
+	maxFallbackMessage = 512
+)
+
+// Metadata keys set by Transport.
+const (
+	MetaDataUpstreamURL     = "upstream_url"
+	MetaDataUpstreamMethod  = "upstream_method"
+	MetaDataUpstreamStatus  = "upstream_status"
+	MetaDataUpstreamHeaders = "upstream_headers"
+)
+
+// FromHTTPResponse decodes an error response written by WriteHTTP,
+// WriteHTTPProblem or WriteHTTPRequest: the morgana JSON, safe JSON and
+// problem+json shapes are recognised. Other bodies produce an HTTP Morgana
+// with the response status and the start of the body as message. The body is
+// read and replaced, so the caller may still read it.
+//
+// Signatures are checked as the policy set with SetSignaturePolicy asks. A
+// document the policy rejects produces the HTTP Morgana for the response
+// status, with the signature error as cause.
+func FromHTTPResponse(resp *http.Response) Morgana {
+	if resp == nil {
+		return nil
+	}
+	var body []byte
+	if resp.Body != nil {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	m, rejected := decodeErrorBody(resp.Header.Get("Content-Type"), body)
+	if m == nil {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > maxFallbackMessage {
+			cut := maxFallbackMessage
+			for cut > 0 && !utf8.RuneStart(msg[cut]) {
+				cut--
+			}
+			msg = msg[:cut] + "..."
+		}
+		if msg == "" {
+			msg = http.StatusText(resp.StatusCode)
+		}
+		if rejected != nil {
+			return New("HTTP").WithStatusCode(resp.StatusCode).WithMessage(http.StatusText(resp.StatusCode)).WithCause(rejected)
+		}
+		return New("HTTP").WithStatusCode(resp.StatusCode).WithMessage(msg)
+	}
+	if m.GetStatusCode() == 0 {
+		m = m.WithStatusCode(resp.StatusCode)
+	}
+	return m
+}
+
+// decodeErrorBody returns the Morgana body describes, or nil. The error is
+// set when the signature policy rejected the document.
+func decodeErrorBody(contentType string, body []byte) (Morgana, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == ProblemJSONContentType {
+		return decodeProblemBody(body)
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") &&
+		!bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return nil, nil
+	}
+
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) != nil {
+		return nil, nil
+	}
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if _, ok := members[k]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("schemaVersion", "customCode", "msg", "statusCode", "stackErrors"):
+		m, err := FromJSON(body)
+		if errors.Is(err, ErrUnsigned) || errors.Is(err, ErrInvalidSignature) {
+			return nil, err
+		}
+		if err == nil {
+			return m, nil
+		}
+	case has("title", "detail", "status"):
+		return decodeProblemBody(body)
+	}
+	return nil, nil
+}
+
+func decodeProblemBody(body []byte) (Morgana, error) {
+	m, err := FromProblemDetails(body)
+	if err != nil {
+		return nil, nil
+	}
+	return checkSignature(m, body)
+}
+
+// Transport is an http.RoundTripper that turns error responses into Morgana
+// errors built as CheckResponse does. Redirects (3xx) are returned unchanged
+// so http.Client can follow them.
+//
+// Transport deliberately departs from the http.RoundTripper contract, which
+// asks for a nil error whenever a response was received: for a status of
+// 400 or more it closes the body and returns a nil response with the error.
+// http.Client wraps that error in a *url.Error, which GetMorgana and
+// errors.As see through. Use it only for clients that want every error
+// status as an error; otherwise keep a plain transport and call
+// CheckResponse on the responses.
+type Transport struct {
+	// Base performs the requests; http.DefaultTransport when nil.
+	Base http.RoundTripper
+	// Type of the returned Morgana; "UPSTREAM" when empty.
+	Type string
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	err = upstreamError(t.Type, req, resp)
+	_ = resp.Body.Close()
+	return nil, err
+}
+
+// CheckResponse returns nil for a response with a status below 400, and
+// otherwise a Morgana error with Type "UPSTREAM", the upstream status code,
+// the upstream URL, method, status and headers as metadata, and the error
+// decoded by FromHTTPResponse attached with WithError. The body is read and
+// replaced, so the caller may still read it, and must still close it:
+//
+//	resp, err := http.Get(url)
+//	if err != nil {
+//		return err
+//	}
+//	defer resp.Body.Close()
+//	if err := morgana.CheckResponse(resp); err != nil {
+//		return err
+//	}
+func CheckResponse(resp *http.Response) error {
+	if resp == nil || resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	req := resp.Request
+	if req == nil {
+		req = &http.Request{URL: &url.URL{}}
+	}
+	return upstreamError("", req, resp)
+}
+
+func upstreamError(typ string, req *http.Request, resp *http.Response) error {
+	upstream := FromHTTPResponse(resp)
+	if typ == "" {
+		typ = "UPSTREAM"
+	}
+	headers := resp.Header.Clone()
+	headers.Del("Set-Cookie")
+	m := New(typ).WithStatusCode(resp.StatusCode).
+		WithMessage(fmt.Sprintf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)).
+		WithAddMetaData(map[string]any{
+			MetaDataUpstreamURL:     req.URL.Redacted(),
+			MetaDataUpstreamMethod:  req.Method,
+			MetaDataUpstreamStatus:  resp.StatusCode,
+			MetaDataUpstreamHeaders: headers,
+		}).
+		WithError(upstream.ToError())
+	return m.ToError()
+}
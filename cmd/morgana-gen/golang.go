@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/bi0dread/morgana"
+)
+
+// goParamTypes are the parameter types a catalog may declare.
+var goParamTypes = map[string]bool{
+	"string": true, "int": true, "int64": true, "uint": true, "uint64": true,
+	"float64": true, "bool": true, "any": true,
+}
+
+type goParam struct {
+	Ident       string
+	Type        string
+	Placeholder string
+}
+
+type goEntry struct {
+	morgana.CatalogEntry
+	Name   string
+	Params []goParam
+}
+
+type goFile struct {
+	Package   string
+	Source    string
+	Entries   []goEntry
+	ImportFmt bool
+}
+
+func generateGo(pkg string, source string, entries []morgana.CatalogEntry) ([]byte, error) {
+	file := goFile{Package: pkg, Source: source}
+	names := make(map[string]string)
+	for _, e := range entries {
+		if err := e.Validate(); err != nil {
+			return nil, err
+		}
+		name := goName(e.CustomCode)
+		if name == "" {
+			return nil, fmt.Errorf("code %q has no usable Go name", e.CustomCode)
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("codes %q and %q both generate the name %s", other, e.CustomCode, name)
+		}
+		names[name] = e.CustomCode
+
+		ge := goEntry{CatalogEntry: e, Name: name}
+		idents := make(map[string]bool)
+		for _, p := range e.Parameters() {
+			typ := p.Type
+			if typ == "" {
+				typ = "string"
+			}
+			if !goParamTypes[typ] {
+				return nil, fmt.Errorf("code %q: unsupported type %q for param %q", e.CustomCode, typ, p.Name)
+			}
+			ident := goIdent(p.Name)
+			if idents[ident] {
+				return nil, fmt.Errorf("code %q: params generate the identifier %s twice", e.CustomCode, ident)
+			}
+			idents[ident] = true
+			if typ != "string" {
+				file.ImportFmt = true
+			}
+			ge.Params = append(ge.Params, goParam{Ident: ident, Type: typ, Placeholder: "{" + p.Name + "}"})
+		}
+		file.Entries = append(file.Entries, ge)
+	}
+
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, file); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// goName turns a code such as USER_NOT_FOUND into UserNotFound.
+func goName(code string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(code, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + strings.ToLower(part[1:]))
+	}
+	name := b.String()
+	if name != "" && unicode.IsDigit(rune(name[0])) {
+		name = "E" + name
+	}
+	return name
+}
+
+// goIdent turns a placeholder name such as user_id into userID-style
+// lower camel case. Names that would clash with a Go keyword, a predeclared
+// identifier or a package the generated code imports get a trailing
+// underscore.
+func goIdent(name string) string {
+	ident := goName(name)
+	if ident == "" {
+		return "param"
+	}
+	ident = strings.ToLower(ident[:1]) + ident[1:]
+	if token.IsKeyword(ident) || types.Universe.Lookup(ident) != nil || ident == "fmt" || ident == "morgana" {
+		ident += "_"
+	}
+	return ident
+}
+
+func (p goParam) Value() string {
+	if p.Type == "string" {
+		return p.Ident
+	}
+	return "fmt.Sprint(" + p.Ident + ")"
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// Code generated by morgana-gen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .ImportFmt}}
+	"fmt"
+{{end}}
+	"github.com/bi0dread/morgana"
+)
+
+// Error codes.
+const (
+{{- range .Entries}}
+	Code{{.Name}} = {{printf "%q" .CustomCode}}
+{{- end}}
+)
+
+// Sentinels for errors.Is.
+var (
+{{- range .Entries}}
+	Err{{.Name}} = morgana.Define({{printf "%q" .Type}}, Code{{.Name}}, {{.StatusCode}}, {{printf "%q" .Message}})
+{{- end}}
+)
+{{range .Entries}}
+// New{{.Name}} returns a {{.Type}} error with code {{.CustomCode}}.
+func New{{.Name}}({{range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Ident}} {{$p.Type}}{{end}}) morgana.Morgana {
+	return morgana.New({{printf "%q" .Type}}).WithCustomCode(Code{{.Name}}).WithStatusCode({{.StatusCode}}).
+		WithMessage({{printf "%q" .Message}}{{range .Params}}, {{printf "%q" .Placeholder}}, {{.Value}}{{end}}).
+		WithStackTrace(3)
+}
+{{end}}
+// RegisterErrors adds every generated code to c.
+func RegisterErrors(c *morgana.Catalog) error {
+	return c.Register(
+{{- range .Entries}}
+		morgana.CatalogEntry{
+			Type:          {{printf "%q" .Type}},
+			CustomCode:    Code{{.Name}},
+			StatusCode:    {{.StatusCode}},
+			Message:       {{printf "%q" .Message}},
+			Severity:      {{printf "%q" .Severity}},
+			Retryable:     {{.Retryable}},
+			DocsURL:       {{printf "%q" .DocsURL}},
+			PublicMessage: {{printf "%q" .PublicMessage}},
+{{- with .CatalogEntry.Params}}
+			Params: []morgana.CatalogParam{
+{{- range .}}
+				{Name: {{printf "%q" .Name}}, Type: {{printf "%q" .Type}}},
+{{- end}}
+			},
+{{- end}}
+		},
+{{- end}}
+	)
+}
+`))
@@ -0,0 +1,74 @@
+// Command morgana-gen generates Go code from a morgana error catalog file.
+//
+// Usage:
+//
+//	//go:generate go run github.com/bi0dread/morgana/cmd/morgana-gen -in errors.yaml -out errors_gen.go -pkg errs
+//
+// The Go output holds a constant per CustomCode, a sentinel Definition for
+// errors.Is, a typed constructor whose parameters are the placeholders of
+// the message template, and a RegisterErrors function for a morgana.Catalog.
+//
+// With -lang ts the output is a TypeScript module with a string-literal union
+// of the codes and interfaces matching the ToJsonSafe wire shape. With
+// -lang proto it is a proto3 file with an ErrorCode enum and a MorganaError
+// message; enum numbers follow the catalog order, so append new codes at the
+// end of the file to keep them stable.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bi0dread/morgana"
+)
+
+func main() {
+	in := flag.String("in", "", "catalog file (.json, .yaml or .yml)")
+	out := flag.String("out", "", "output file (default stdout)")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file (go and proto)")
+	lang := flag.String("lang", "go", "output language: go, ts or proto")
+	flag.Parse()
+
+	if err := run(*in, *out, *pkg, *lang); err != nil {
+		fmt.Fprintln(os.Stderr, "morgana-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, pkg, lang string) error {
+	if in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	if pkg == "" && lang != "ts" {
+		return fmt.Errorf("-pkg is required outside go generate")
+	}
+
+	catalog := morgana.NewCatalog()
+	if err := catalog.LoadFile(in); err != nil {
+		return err
+	}
+
+	var src []byte
+	var err error
+	switch lang {
+	case "go":
+		src, err = generateGo(pkg, filepath.Base(in), catalog.Entries())
+	case "ts":
+		src, err = generateTS(filepath.Base(in), catalog.Entries())
+	case "proto":
+		src, err = generateProto(pkg, filepath.Base(in), catalog.Entries())
+	default:
+		err = fmt.Errorf("unknown -lang %q", lang)
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCatalog = `
+errors:
+  - type: USER
+    customCode: USER_NOT_FOUND
+    statusCode: 404
+    message: "user {id} not found in {tenant}"
+    params:
+      - {name: id, type: int}
+      - {name: tenant}
+  - type: VALIDATION
+    customCode: INVALID_INPUT
+    statusCode: 400
+    message: "field {type} is invalid"
+`
+
+// checkFset and checkImporter are shared so the morgana package is only
+// type-checked from source once.
+var (
+	checkFset     = token.NewFileSet()
+	checkImporter = importer.ForCompiler(checkFset, "source", nil)
+)
+
+// typeCheck parses and type-checks generated Go source as a package of its
+// own, resolving the morgana import from source.
+func typeCheck(t *testing.T, path string, src []byte) {
+	t.Helper()
+	f, err := parser.ParseFile(checkFset, path, src, 0)
+	require.NoError(t, err)
+	conf := types.Config{Importer: checkImporter}
+	_, err = conf.Check(f.Name.Name, checkFset, []*ast.File{f}, nil)
+	require.NoError(t, err)
+}
+
+func writeCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "errors.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestGenerateGo(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "errors_gen.go")
+	require.NoError(t, run(writeCatalog(t, testCatalog), out, "errs", "go"))
+
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	typeCheck(t, out, src)
+
+	code := string(src)
+	assert.Contains(t, code, `CodeUserNotFound = "USER_NOT_FOUND"`)
+	assert.Contains(t, code, `ErrUserNotFound = morgana.Define("USER", CodeUserNotFound, 404,`)
+	assert.Contains(t, code, `func NewUserNotFound(id int, tenant string) morgana.Morgana`)
+	assert.Contains(t, code, `"{id}", fmt.Sprint(id), "{tenant}", tenant`)
+	assert.Contains(t, code, `func NewInvalidInput(type_ string) morgana.Morgana`)
+	assert.Contains(t, code, `func RegisterErrors(c *morgana.Catalog) error`)
+	assert.Contains(t, code, `Params: []morgana.CatalogParam{`)
+	assert.Contains(t, code, `{Name: "id", Type: "int"},`)
+	assert.Contains(t, code, `{Name: "tenant", Type: ""},`)
+}
+
+func TestGenerateGoSanitizesIdentifiers(t *testing.T) {
+	clashing := `
+errors:
+  - type: RENDER
+    customCode: RENDER_FAILED
+    statusCode: 500
+    message: "{fmt} {morgana} {nil} {string} {len} {c} {func}"
+    params:
+      - {name: fmt, type: int}
+      - {name: morgana}
+      - {name: nil}
+      - {name: string}
+      - {name: len, type: bool}
+      - {name: c}
+      - {name: func}
+`
+	out := filepath.Join(t.TempDir(), "errors_gen.go")
+	require.NoError(t, run(writeCatalog(t, clashing), out, "errs", "go"))
+
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	typeCheck(t, out, src)
+	assert.Contains(t, string(src), `func NewRenderFailed(fmt_ int, morgana_ string, nil_ string, string_ string, len_ bool, c string, func_ string) morgana.Morgana`)
+}
+
+func TestGenerateGoRejectsMismatch(t *testing.T) {
+	undeclared := `
+errors:
+  - type: USER
+    customCode: USER_NOT_FOUND
+    statusCode: 404
+    message: "user {id} not found in {tenant}"
+    params:
+      - {name: id}
+`
+	assert.ErrorContains(t, run(writeCatalog(t, undeclared), "", "errs", "go"), "undeclared placeholder {tenant}")
+
+	unused := `
+errors:
+  - type: USER
+    customCode: USER_NOT_FOUND
+    statusCode: 404
+    message: "user not found"
+    params:
+      - {name: id}
+`
+	assert.ErrorContains(t, run(writeCatalog(t, unused), "", "errs", "go"), `param "id" missing`)
+
+	badType := `
+errors:
+  - type: USER
+    customCode: USER_NOT_FOUND
+    statusCode: 404
+    message: "user {id}"
+    params:
+      - {name: id, type: chan}
+`
+	assert.ErrorContains(t, run(writeCatalog(t, badType), "", "errs", "go"), "unsupported type")
+}
+
+func TestGenerateTS(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "errors.ts")
+	require.NoError(t, run(writeCatalog(t, testCatalog), out, "", "ts"))
+
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	code := string(src)
+	assert.Contains(t, code, `export type ErrorCode = "USER_NOT_FOUND" | "INVALID_INPUT";`)
+	assert.Contains(t, code, `"USER_NOT_FOUND": 404,`)
+	assert.Contains(t, code, `customCode?: ErrorCode;`)
+	assert.Contains(t, code, `fieldErrors?: FieldError[];`)
+	assert.Contains(t, code, `stackFrames?: StackFrame[];`)
+	assert.Contains(t, code, "export interface Annotation {\n  msg: string;\n  frame: StackFrame;\n  metaData?: Record<string, unknown>;\n}")
+	assert.Contains(t, code, `annotations?: Annotation[];`)
+}
+
+func TestGenerateProto(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "errors.proto")
+	require.NoError(t, run(writeCatalog(t, testCatalog), out, "acme.errors", "proto"))
+
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	code := string(src)
+	assert.Contains(t, code, "package acme.errors;")
+	assert.Contains(t, code, "ERROR_CODE_UNSPECIFIED = 0;")
+	assert.Contains(t, code, "USER_NOT_FOUND = 1;")
+	assert.Contains(t, code, "INVALID_INPUT = 2;")
+	assert.Contains(t, code, "ErrorCode custom_code = 5;")
+	assert.Contains(t, code, "message Annotation {\n  string msg = 1;\n  StackFrame frame = 2;\n  google.protobuf.Struct meta_data = 3;\n}")
+	assert.Contains(t, code, "repeated Annotation annotations = 11;")
+	assert.Equal(t, "E_404_X", protoName("404-x"))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/bi0dread/morgana"
+)
+
+type protoValue struct {
+	Name   string
+	Number int
+	Code   string
+}
+
+type protoFile struct {
+	Package string
+	Source  string
+	Values  []protoValue
+}
+
+func generateProto(pkg string, source string, entries []morgana.CatalogEntry) ([]byte, error) {
+	file := protoFile{Package: pkg, Source: source}
+	seen := map[string]string{"ERROR_CODE_UNSPECIFIED": ""}
+	for i, e := range entries {
+		name := protoName(e.CustomCode)
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("codes %q and %q both generate the enum value %s", other, e.CustomCode, name)
+		}
+		seen[name] = e.CustomCode
+		file.Values = append(file.Values, protoValue{Name: name, Number: i + 1, Code: e.CustomCode})
+	}
+
+	var buf bytes.Buffer
+	if err := protoTemplate.Execute(&buf, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// protoName keeps codes such as USER_NOT_FOUND unchanged so the proto JSON
+// mapping of the enum matches the customCode sent on the wire.
+func protoName(code string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, code)
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "E_" + name
+	}
+	return name
+}
+
+var protoTemplate = template.Must(template.New("proto").Parse(`// Code generated by morgana-gen from {{.Source}}. DO NOT EDIT.
+
+syntax = "proto3";
+
+package {{.Package}};
+
+import "google/protobuf/struct.proto";
+
+// ErrorCode numbers follow the catalog order.
+enum ErrorCode {
+  ERROR_CODE_UNSPECIFIED = 0;
+{{- range .Values}}
+  {{.Name}} = {{.Number}};{{if ne .Name .Code}} // {{.Code}}{{end}}
+{{- end}}
+}
+
+message StackFrame {
+  string file = 1;
+  int32 line = 2;
+  string function = 3;
+}
+
+message FieldError {
+  string field = 1;
+  string code = 2;
+  string msg = 3;
+}
+
+message Annotation {
+  string msg = 1;
+  StackFrame frame = 2;
+  google.protobuf.Struct meta_data = 3;
+}
+
+// MorganaError mirrors the JSON produced by ToJsonSafe.
+message MorganaError {
+  string type = 1;
+  string with = 2;
+  string msg = 3;
+  int32 status_code = 4;
+  ErrorCode custom_code = 5;
+  string stack_trace = 6;
+  repeated StackFrame stack_frames = 7;
+  google.protobuf.Struct meta_data = 8;
+  repeated FieldError field_errors = 9;
+  string id = 10;
+  repeated Annotation annotations = 11;
+}
+`))
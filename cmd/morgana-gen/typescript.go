@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/bi0dread/morgana"
+)
+
+type tsFile struct {
+	Source  string
+	Entries []morgana.CatalogEntry
+}
+
+func generateTS(source string, entries []morgana.CatalogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tsTemplate.Execute(&buf, tsFile{Source: source, Entries: entries}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tsTemplate mirrors the JSON produced by ToJsonSafe.
+var tsTemplate = template.Must(template.New("ts").Parse(`// Code generated by morgana-gen from {{.Source}}. DO NOT EDIT.
+
+export const ErrorCodes = {
+{{- range .Entries}}
+  {{printf "%q" .CustomCode}}: {{printf "%q" .CustomCode}},
+{{- end}}
+} as const;
+
+export type ErrorCode = {{if .Entries}}{{range $i, $e := .Entries}}{{if $i}} | {{end}}{{printf "%q" $e.CustomCode}}{{end}}{{else}}never{{end}};
+
+export const ErrorStatusCodes: Record<ErrorCode, number> = {
+{{- range .Entries}}
+  {{printf "%q" .CustomCode}}: {{.StatusCode}},
+{{- end}}
+};
+
+export function isErrorCode(code: unknown): code is ErrorCode {
+  return typeof code === "string" && Object.prototype.hasOwnProperty.call(ErrorCodes, code);
+}
+
+export interface StackFrame {
+  file?: string;
+  line?: number;
+  function?: string;
+}
+
+export interface FieldError {
+  field: string;
+  code?: string;
+  msg: string;
+}
+
+export interface Annotation {
+  msg: string;
+  frame: StackFrame;
+  metaData?: Record<string, unknown>;
+}
+
+export interface MorganaError {
+  type?: string;
+  with?: string;
+  msg?: string;
+  statusCode?: number;
+  customCode?: ErrorCode;
+  stackTrace?: string;
+  stackFrames?: StackFrame[];
+  metaData?: Record<string, unknown>;
+  fieldErrors?: FieldError[];
+  annotations?: Annotation[];
+  id?: string;
+}
+`))
@@ -0,0 +1,126 @@
+// Command morgana works with documents written by the morgana package.
+//
+// Usage:
+//
+//	morgana decrypt [-keys keys.json] [-key id=base64key]... < error.json
+//
+// decrypt reads JSON documents, such as ToJsonSafe output or JSON log lines,
+// from standard input and writes each one to standard output, on its own
+// line, with the values encrypted by morgana.RedactEncrypt restored. Values
+// whose key is not given are written unchanged. Keys are
+// base64-encoded AES keys given with -key, in a -keys file holding a JSON
+// object from key ID to key, or in MORGANA_KEYS as comma-separated id=key
+// pairs.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bi0dread/morgana"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Getenv("MORGANA_KEYS")); err != nil {
+		fmt.Fprintln(os.Stderr, "morgana:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, envKeys string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: morgana decrypt [-keys file] [-key id=key]...")
+	}
+	switch args[0] {
+	case "decrypt":
+		return decrypt(args[1:], stdin, stdout, envKeys)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// keyFlags collects repeated -key id=base64key flags.
+type keyFlags []string
+
+func (k *keyFlags) String() string {
+	return strings.Join(*k, ",")
+}
+
+func (k *keyFlags) Set(v string) error {
+	*k = append(*k, v)
+	return nil
+}
+
+func decrypt(args []string, stdin io.Reader, stdout io.Writer, envKeys string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keysFile := fs.String("keys", "", "JSON file mapping key IDs to base64 keys")
+	var keys keyFlags
+	fs.Var(&keys, "key", "key as id=base64key (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ring := morgana.NewKeyRing()
+	if envKeys != "" {
+		keys = append(strings.Split(envKeys, ","), keys...)
+	}
+	for _, pair := range keys {
+		id, key, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("key %q is not id=base64key", pair)
+		}
+		if err := addKey(ring, id, key); err != nil {
+			return err
+		}
+	}
+	if *keysFile != "" {
+		data, err := os.ReadFile(*keysFile)
+		if err != nil {
+			return err
+		}
+		var fileKeys map[string]string
+		if err := json.Unmarshal(data, &fileKeys); err != nil {
+			return fmt.Errorf("%s: %w", *keysFile, err)
+		}
+		for id, key := range fileKeys {
+			if err := addKey(ring, id, key); err != nil {
+				return err
+			}
+		}
+	}
+	if ring.Primary() == "" {
+		return fmt.Errorf("no keys given")
+	}
+
+	dec := json.NewDecoder(stdin)
+	for {
+		var doc json.RawMessage
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		out, err := morgana.DecryptJSON(ring, doc)
+		if err != nil {
+			return err
+		}
+		if _, err := stdout.Write(append(bytes.Clone(out), '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+func addKey(ring *morgana.KeyRing, id, key string) error {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", id, err)
+	}
+	return ring.Add(id, raw)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecrypt(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	ring := morgana.NewKeyRing()
+	require.NoError(t, ring.Add("k1", oldKey))
+	require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{Default: morgana.RedactEncrypt(ring)}))
+	defer func() { require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{})) }()
+
+	first := morgana.New("AUTH").WithAddMetaDataKey("token", "s3cr3t").WithRedactedKey("token").ToJsonSafe()
+	require.NoError(t, ring.Add("k2", newKey))
+	require.NoError(t, ring.SetPrimary("k2"))
+	second := morgana.New("AUTH").WithAddMetaDataKey("pin", 1234).WithRedactedKey("pin").ToJsonSafe()
+	assert.NotContains(t, first+second, "s3cr3t")
+	assert.Contains(t, second, "[enc:k2:")
+
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(keysFile, []byte(`{"k2":"`+base64.StdEncoding.EncodeToString(newKey)+`"}`), 0o600))
+
+	var out bytes.Buffer
+	err := run([]string{"decrypt", "-keys", keysFile}, strings.NewReader(first+"\n"+second), &out, "k1="+base64.StdEncoding.EncodeToString(oldKey))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"token":"s3cr3t"`)
+	assert.Contains(t, lines[1], `"pin":1234`)
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		var out bytes.Buffer
+		err := run([]string{"decrypt", "-key", "k1=" + base64.StdEncoding.EncodeToString(oldKey)}, strings.NewReader(first+second), &out, "")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"token":"s3cr3t"`)
+		assert.Contains(t, lines[1], `"pin":"[enc:k2:`)
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		assert.Error(t, run(nil, nil, &out, ""))
+		assert.Error(t, run([]string{"decrypt"}, strings.NewReader("{}"), &out, ""))
+	})
+}
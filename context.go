@@ -0,0 +1,73 @@
+package morgana
+
+import (
+	"context"
+	"maps"
+)
+
+// fieldLayer is one ContextWithFields call; layers form a chain towards the
+// outermost call.
+type fieldLayer struct {
+	parent *fieldLayer
+	fields map[string]any
+}
+
+// ContextWithFields returns a context carrying fields on top of those already
+// in ctx, much like a logger's With. Every Morgana built with NewCtx or
+// WithContext from the returned context, or from contexts derived from it,
+// gets the accumulated fields as metadata. Inner fields override outer ones
+// with the same key.
+func ContextWithFields(ctx context.Context, fields map[string]any) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	parent, _ := ctx.Value(fieldsKey).(*fieldLayer)
+	return context.WithValue(ctx, fieldsKey, &fieldLayer{parent: parent, fields: maps.Clone(fields)})
+}
+
+// FieldsFromContext returns the fields accumulated in ctx, or nil.
+func FieldsFromContext(ctx context.Context) map[string]any {
+	if ctx == nil {
+		return nil
+	}
+	layer, _ := ctx.Value(fieldsKey).(*fieldLayer)
+	if layer == nil {
+		return nil
+	}
+	var layers []*fieldLayer
+	for ; layer != nil; layer = layer.parent {
+		layers = append(layers, layer)
+	}
+	out := make(map[string]any)
+	for i := len(layers) - 1; i >= 0; i-- {
+		maps.Copy(out, layers[i].fields)
+	}
+	return out
+}
+
+// NewCtx is New followed by WithContext.
+func NewCtx(ctx context.Context, typeValue string) Morgana {
+	return New(typeValue).WithContext(ctx)
+}
+
+// WithContext adds the fields accumulated with ContextWithFields and the
+// values WithTrace would add to the metadata. Explicit metadata wins: keys
+// the Morgana already has are left unchanged.
+func (m *morgana) WithContext(ctx context.Context) Morgana {
+	if ctx == nil {
+		return m
+	}
+	trace, redact := traceValues(ctx)
+	var out Morgana = m
+	for _, md := range []map[string]any{FieldsFromContext(ctx), trace} {
+		for k, v := range md {
+			if !out.HasMetaDataKey(k) {
+				out = out.WithAddMetaDataKey(k, v)
+			}
+		}
+	}
+	for _, k := range redact {
+		out = out.WithRedactedKey(k)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package morgana
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Definition is an error kind declared once, usually as a package-level
+// variable, and instantiated per call with New. A Definition cannot be
+// changed after Define returns, and every instance satisfies
+// errors.Is(err, definition): a Definition compares Type and CustomCode only,
+// whatever With value or match mode the instance carries.
+//
+//	var ErrUserNotFound = morgana.Define("USER", "USER_NOT_FOUND", 404, "user {id} not found")
+//
+//	return ErrUserNotFound.New(map[string]any{"id": id}).WithAddMetaDataKey("tenant", t).ToError()
+type Definition struct {
+	proto *morgana
+}
+
+// Define declares an error kind. msg may contain {name} placeholders that
+// are filled from the params passed to New.
+func Define(typeValue string, customCode string, statusCode int, msg string) *Definition {
+	return &Definition{proto: &morgana{
+		Type:       typeValue,
+		CustomCode: customCode,
+		StatusCode: statusCode,
+		Msg:        msg,
+		immutable:  true,
+		matchMode:  matchKind,
+	}}
+}
+
+// New returns a fresh, mutable Morgana of this kind with its placeholders
+// filled from params, a new ID and the caller's stack trace.
+func (d *Definition) New(params map[string]any) Morgana {
+	m := New(d.proto.Type).WithCustomCode(d.proto.CustomCode).WithStatusCode(d.proto.StatusCode).
+		WithMessage(renderMessage(d.proto.Msg, params))
+	return m.WithStackTrace(3)
+}
+
+func (d *Definition) Type() string {
+	return d.proto.Type
+}
+
+func (d *Definition) CustomCode() string {
+	return d.proto.CustomCode
+}
+
+func (d *Definition) StatusCode() int {
+	return d.proto.StatusCode
+}
+
+// Message returns the unrendered message template.
+func (d *Definition) Message() string {
+	return d.proto.Msg
+}
+
+func (d *Definition) Error() string {
+	return d.proto.stringSimple()
+}
+
+// Is reports whether target has the identity of this Definition: its Type
+// and CustomCode, whatever the match mode or With value of target.
+func (d *Definition) Is(target error) bool {
+	t := morganaOf(target)
+	return t != nil && t.CustomCode == d.proto.CustomCode && t.Type == d.proto.Type
+}
+
+// As sets a *Morgana target to the immutable prototype of the Definition,
+// with the unrendered message and no ID, so GetMorgana and FromError see a
+// Definition returned as an error as its own kind.
+func (d *Definition) As(target any) bool {
+	if t, ok := target.(*Morgana); ok {
+		*t = d.proto
+		return true
+	}
+	return false
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Placeholders returns the distinct {name} placeholders of a message
+// template in order of first appearance.
+func Placeholders(msg string) []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, match := range placeholderPattern.FindAllStringSubmatch(msg, -1) {
+		if _, ok := seen[match[1]]; ok {
+			continue
+		}
+		seen[match[1]] = struct{}{}
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// renderMessage fills {name} placeholders from params. Placeholders without
+// a matching param are left as they are.
+func renderMessage(msg string, params map[string]any) string {
+	if len(params) == 0 || !strings.Contains(msg, "{") {
+		return msg
+	}
+	return placeholderPattern.ReplaceAllStringFunc(msg, func(p string) string {
+		if v, ok := params[p[1:len(p)-1]]; ok {
+			return fmt.Sprint(v)
+		}
+		return p
+	})
+}
@@ -39,12 +39,44 @@ func (e *empo) WithCause(err error) Empo {
 }
 
 func (e *empo) Error() string {
+	if m, ok := e.details[morgana_key_data].(Morgana); ok {
+		return m.Error()
+	}
 	return e.msg
 }
 
-// Unwrap enables errors.Is/As to traverse the cause chain
-func (e *empo) Unwrap() error {
-	return e.cause
+// Unwrap enables errors.Is/As to traverse the cause chain. For an error
+// returned by Morgana.ToError it also yields every stack error of the Morgana.
+// Because it returns a slice, errors.Unwrap returns nil for such errors; use
+// GetMorgana(err).Cause() to get the cause.
+func (e *empo) Unwrap() []error {
+	if m, ok := e.details[morgana_key_data].(*morgana); ok {
+		return m.unwrapAll()
+	}
+	if e.cause == nil {
+		return nil
+	}
+	return []error{e.cause}
+}
+
+// As lets errors.As find the Morgana carried in the attributes.
+func (e *empo) As(target any) bool {
+	if t, ok := target.(*Morgana); ok {
+		if m, ok := e.details[morgana_key_data].(Morgana); ok {
+			*t = m
+			return true
+		}
+	}
+	return false
+}
+
+// Is delegates to the identity of the carried Morgana, so errors.Is matches
+// errors returned by ToError against templates and their clones.
+func (e *empo) Is(target error) bool {
+	if m, ok := e.details[morgana_key_data].(Morgana); ok {
+		return m.Is(target)
+	}
+	return false
 }
 
 func GetEmpo(err error) Empo {
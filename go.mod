@@ -3,12 +3,11 @@ module github.com/bi0dread/morgana
 go 1.23.2
 
 require (
-	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
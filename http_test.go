@@ -0,0 +1,370 @@
+package morgana_test
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTTPRequest(t *testing.T) {
+	m := morgana.New("NotFound").WithStatusCode(http.StatusNotFound).WithCustomCode("MISSING").
+		WithMessage("<b>item</b> not found").WithAddMetaDataKey("token", "s3cr3t").WithRedactedKey("token")
+
+	write := func(accept string, opts morgana.HTTPOptions) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		m.WriteHTTPRequest(rec, req, opts)
+		return rec
+	}
+
+	cases := []struct {
+		accept      string
+		contentType string
+		contains    string
+	}{
+		{"", "application/json", `"customCode":"MISSING"`},
+		{"application/problem+json", "application/problem+json", `"status":404`},
+		{"application/xml", "application/xml", `<customCode>MISSING</customCode>`},
+		{"text/plain", "text/plain; charset=utf-8", "404 Not Found"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8", "&lt;b&gt;item&lt;/b&gt;"},
+		{"application/json;q=0.5, text/*", "text/plain; charset=utf-8", "MISSING"},
+		{"image/png", "application/json", `"id":`},
+		{"text/html;q=0", "application/json", `"msg":`},
+		{"application/xml;q=0.7, text/plain;q=0.7", "application/xml", `<customCode>`},
+		{"text/plain;q=0.7, application/xml;q=0.7", "text/plain; charset=utf-8", "MISSING"},
+	}
+	for _, c := range cases {
+		t.Run(c.accept, func(t *testing.T) {
+			rec := write(c.accept, morgana.HTTPOptions{Safe: true})
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Equal(t, c.contentType, rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Body.String(), c.contains)
+			assert.NotContains(t, rec.Body.String(), "s3cr3t")
+			if strings.HasSuffix(c.contentType, "json") {
+				assert.False(t, strings.HasSuffix(rec.Body.String(), "\n"))
+			}
+		})
+	}
+
+	t.Run("CustomHTMLTemplate", func(t *testing.T) {
+		tmpl := template.Must(template.New("page").Parse(`<p class="err">{{.Status}} {{.CustomCode}}</p>`))
+		rec := write("text/html", morgana.HTTPOptions{HTMLTemplate: tmpl})
+		assert.Equal(t, `<p class="err">404 MISSING</p>`, rec.Body.String())
+	})
+
+	t.Run("RegisteredRenderer", func(t *testing.T) {
+		morgana.RegisterRenderer("application/vnd.test", func(w io.Writer, m morgana.Morgana, safe bool) error {
+			_, err := io.WriteString(w, "custom:"+m.GetCustomCode())
+			return err
+		})
+		rec := write("application/vnd.test", morgana.HTTPOptions{})
+		assert.Equal(t, "application/vnd.test", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "custom:MISSING", rec.Body.String())
+	})
+}
+
+func TestFromHTTPResponse(t *testing.T) {
+	source := morgana.New("Conflict").WithStatusCode(http.StatusConflict).WithCustomCode("DUP").WithMessage("duplicate")
+
+	cases := map[string]func(w http.ResponseWriter){
+		"json":    func(w http.ResponseWriter) { source.WriteHTTP(w, false) },
+		"safe":    func(w http.ResponseWriter) { source.WriteHTTP(w, true) },
+		"problem": func(w http.ResponseWriter) { source.WriteHTTPProblem(w) },
+	}
+	for name, write := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			write(rec)
+			m := morgana.FromHTTPResponse(rec.Result())
+			assert.Equal(t, "Conflict", m.GetType())
+			assert.Equal(t, "DUP", m.GetCustomCode())
+			assert.Equal(t, "duplicate", m.GetMessage())
+			assert.Equal(t, http.StatusConflict, m.GetStatusCode())
+			assert.Equal(t, source.GetID(), m.GetID())
+		})
+	}
+
+	t.Run("Fallback", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(http.StatusBadGateway)
+		_, _ = rec.WriteString("<html>" + strings.Repeat("x", 1000) + "</html>")
+		resp := rec.Result()
+		m := morgana.FromHTTPResponse(resp)
+		assert.Equal(t, "HTTP", m.GetType())
+		assert.Equal(t, http.StatusBadGateway, m.GetStatusCode())
+		assert.Less(t, len(m.GetMessage()), 600)
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "<html>")
+	})
+
+	t.Run("FallbackTruncatesOnRuneBoundary", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(http.StatusBadGateway)
+		_, _ = rec.WriteString("x" + strings.Repeat("é", 600))
+		msg := morgana.FromHTTPResponse(rec.Result()).GetMessage()
+		assert.True(t, utf8.ValidString(msg))
+		assert.True(t, strings.HasSuffix(msg, "é..."), msg)
+	})
+}
+
+func TestTransport(t *testing.T) {
+	upstream := morgana.New("NotFound").WithStatusCode(http.StatusNotFound).WithCustomCode("NO_USER")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			_, _ = io.WriteString(w, "fine")
+			return
+		}
+		w.Header().Set("X-Upstream", "users")
+		w.Header().Set("Set-Cookie", "session=abc")
+		upstream.WriteHTTP(w, true)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: morgana.NewTransport(nil)}
+
+	resp, err := client.Get(srv.URL + "/ok")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	_, err = client.Get(srv.URL + "/users/1")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, upstream)
+
+	m := morgana.GetMorgana(err)
+	require.NotNil(t, m)
+	assert.Equal(t, "UPSTREAM", m.GetType())
+	assert.Equal(t, http.StatusNotFound, m.GetStatusCode())
+	assert.Equal(t, srv.URL+"/users/1", m.GetMetaDataKey(morgana.MetaDataUpstreamURL))
+	assert.Equal(t, http.MethodGet, m.GetMetaDataKey(morgana.MetaDataUpstreamMethod))
+	headers := m.GetMetaDataKey(morgana.MetaDataUpstreamHeaders).(http.Header)
+	assert.Equal(t, "users", headers.Get("X-Upstream"))
+	assert.Empty(t, headers.Get("Set-Cookie"))
+	require.Len(t, m.GetMorganaStackErrors(), 1)
+	assert.Equal(t, "NO_USER", m.GetMorganaStackErrors()[0].GetCustomCode())
+
+	t.Run("CheckResponse", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/users/1")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		err = morgana.CheckResponse(resp)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, upstream)
+		m := morgana.GetMorgana(err)
+		assert.Equal(t, "UPSTREAM", m.GetType())
+		assert.Equal(t, srv.URL+"/users/1", m.GetMetaDataKey(morgana.MetaDataUpstreamURL))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "NO_USER")
+
+		ok, err := http.Get(srv.URL + "/ok")
+		require.NoError(t, err)
+		defer ok.Body.Close()
+		assert.NoError(t, morgana.CheckResponse(ok))
+		assert.NoError(t, morgana.CheckResponse(nil))
+	})
+}
+
+func TestHandlerAndMiddleware(t *testing.T) {
+	errNotFound := morgana.Define("ITEM", "ITEM_NOT_FOUND", http.StatusNotFound, "item {id} not found")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /items/{id}", morgana.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("lookup: %w", errNotFound.New(map[string]any{"id": r.PathValue("id")}).ToError())
+	}))
+	mux.Handle("GET /plain", morgana.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("plain failure")
+	}))
+	mux.Handle("GET /panic", morgana.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	mux.Handle("GET /batch", morgana.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("batch: %w", morgana.Join(
+			morgana.New("NotFound").WithStatusCode(http.StatusNotFound).ToError(),
+			morgana.New("Down").WithStatusCode(http.StatusServiceUnavailable).ToError(),
+		))
+	}))
+	mux.Handle("GET /routed", morgana.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return morgana.New("Routed").WithStatusCode(http.StatusConflict).
+			WithAddMetaDataKey(morgana.MetaDataHTTPRoute, "custom").WithAddMetaDataKey(morgana.MetaDataHTTPMethod, "CUSTOM")
+	}))
+	mux.Handle("GET /stream", morgana.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("hijack: %v", err)
+		}
+		return errors.New("stream broke")
+	}))
+	mux.Handle("GET /partial", morgana.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("too late")
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("ReturnedMorgana", func(t *testing.T) {
+		rec := serve("/items/7")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		m, err := morgana.FromJSON(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "item 7 not found", m.GetMessage())
+		assert.Equal(t, "GET /items/{id}", m.GetMetaDataKey(morgana.MetaDataHTTPRoute))
+		assert.Equal(t, http.MethodGet, m.GetMetaDataKey(morgana.MetaDataHTTPMethod))
+		assert.Equal(t, "req-1", m.GetMetaDataKey(morgana.MetaDataRequestID))
+	})
+
+	t.Run("ForeignError", func(t *testing.T) {
+		rec := serve("/plain")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "plain failure")
+		m, err := morgana.FromJSON(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "GENERAL", m.GetType())
+		assert.Equal(t, http.StatusText(http.StatusInternalServerError), m.GetMessage())
+
+		var logged morgana.Morgana
+		opts := morgana.ServerOptions{OnError: func(r *http.Request, m morgana.Morgana) { logged = m }}
+		cause := errors.New("dial postgres://app:hunter2@db/app")
+		rec = httptest.NewRecorder()
+		opts.Handler(func(w http.ResponseWriter, r *http.Request) error { return cause }).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "hunter2")
+		require.NotNil(t, logged)
+		assert.ErrorIs(t, logged.ToError(), cause)
+	})
+
+	t.Run("WrappedJoin", func(t *testing.T) {
+		rec := serve("/batch")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		m, err := morgana.FromJSON(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "MULTI", m.GetType())
+		assert.Equal(t, "2 errors occurred", m.GetMessage())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		rec := serve("/panic")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), "panic: boom")
+	})
+
+	t.Run("ExplicitRequestMetaDataWins", func(t *testing.T) {
+		rec := serve("/routed")
+		m, err := morgana.FromJSON(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "custom", m.GetMetaDataKey(morgana.MetaDataHTTPRoute))
+		assert.Equal(t, "CUSTOM", m.GetMetaDataKey(morgana.MetaDataHTTPMethod))
+		assert.Equal(t, "req-1", m.GetMetaDataKey(morgana.MetaDataRequestID))
+	})
+
+	t.Run("FlushAndHijack", func(t *testing.T) {
+		rec := serve("/stream")
+		assert.True(t, rec.Flushed)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "partial", rec.Body.String())
+	})
+
+	t.Run("ResponseAlreadyStarted", func(t *testing.T) {
+		rec := serve("/partial")
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("UnsafePolicy", func(t *testing.T) {
+		var logged morgana.Morgana
+		opts := morgana.ServerOptions{
+			Unsafe:  func(r *http.Request, m morgana.Morgana) bool { return r.Header.Get("X-Debug") == "1" },
+			OnError: func(r *http.Request, m morgana.Morgana) { logged = m },
+		}
+		h := opts.Handler(func(w http.ResponseWriter, r *http.Request) error {
+			return morgana.New("Secret").WithAddMetaDataKey("token", "s3cr3t").WithRedactedKey("token")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.NotContains(t, rec.Body.String(), "s3cr3t")
+		assert.NotNil(t, logged)
+
+		req.Header.Set("X-Debug", "1")
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Contains(t, rec.Body.String(), `"schemaVersion"`)
+		assert.NotContains(t, rec.Body.String(), "s3cr3t")
+	})
+}
+
+func TestCorrelationMiddleware(t *testing.T) {
+	var traced morgana.Morgana
+	h := morgana.CorrelationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = morgana.New("Traced").WithTrace(r.Context())
+	}))
+
+	t.Run("PropagatesHeaders", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "req-42")
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, "req-42", rec.Header().Get("X-Request-ID"))
+		assert.Equal(t, "req-42", traced.GetMetaDataKey(morgana.MetaDataRequestID))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traced.GetMetaDataKey(morgana.MetaDataTraceID))
+		assert.Equal(t, "00f067aa0ba902b7", traced.GetMetaDataKey(morgana.MetaDataSpanID))
+		assert.Equal(t, "congo=t61rcWkgMzE", traced.GetMetaDataKey(morgana.MetaDataTraceState))
+	})
+
+	t.Run("GeneratesRequestID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get("X-Request-ID")
+		assert.Len(t, id, 32)
+		assert.Equal(t, id, traced.GetMetaDataKey(morgana.MetaDataRequestID))
+		assert.False(t, traced.HasMetaDataKey(morgana.MetaDataTraceID))
+	})
+}
+
+func TestParseTraceParent(t *testing.T) {
+	traceID, spanID, ok := morgana.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "00f067aa0ba902b7", spanID)
+
+	for _, invalid := range []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, _, ok := morgana.ParseTraceParent(invalid)
+		assert.False(t, ok, invalid)
+	}
+	_, _, ok = morgana.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+	assert.True(t, ok)
+}
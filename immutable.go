@@ -0,0 +1,150 @@
+package morgana
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// Immutable returns a frozen deep copy of the Morgana. Its stack errors are
+// frozen too, and GetMetaData and GetMorganaStackErrors return copies. Every
+// With* call on the returned value leaves it untouched and yields a new
+// Morgana that shares the unchanged parts, which makes it safe to keep as a
+// package-level template and to hand to other goroutines.
+func (m *morgana) Immutable() Morgana {
+	return m.deepCopy(true)
+}
+
+func (m *morgana) IsImmutable() bool {
+	return m.immutable
+}
+
+// DeepClone returns an independent, mutable copy of the Morgana. Metadata
+// values, stack errors, stack frames, field errors, annotations and redacted
+// keys are copied; the ID, stack trace and cause are kept as they are.
+func (m *morgana) DeepClone() Morgana {
+	return m.deepCopy(false)
+}
+
+// edit returns the value a With* method should modify: m itself when it is
+// mutable, or a shallow copy of m when it is immutable. Callers changing a map
+// or appending to a slice of an immutable Morgana must copy or clip it first.
+func (m *morgana) edit() *morgana {
+	if !m.immutable {
+		return m
+	}
+	c := *m
+	return &c
+}
+
+// deepCopy copies m and its stack errors, marking every copy immutable or
+// mutable as asked.
+func (m *morgana) deepCopy(immutable bool) *morgana {
+	return m.deepCopySeen(make(map[*morgana]*morgana), immutable)
+}
+
+func (m *morgana) deepCopySeen(seen map[*morgana]*morgana, immutable bool) *morgana {
+	if c, ok := seen[m]; ok {
+		return c
+	}
+	c := *m
+	c.immutable = immutable
+	seen[m] = &c
+	c.MetaData = deepCopyMap(m.MetaData)
+	c.StackFrames = slices.Clone(m.StackFrames)
+	c.FieldErrors = slices.Clone(m.FieldErrors)
+	c.Annotations = copyAnnotations(m.Annotations)
+	c.redactedKeys = maps.Clone(m.redactedKeys)
+	if m.morganaStackErrors != nil {
+		c.morganaStackErrors = make([]Morgana, 0, len(m.morganaStackErrors))
+		for _, stackError := range m.morganaStackErrors {
+			if sm, ok := stackError.(*morgana); ok {
+				c.morganaStackErrors = append(c.morganaStackErrors, sm.deepCopySeen(seen, immutable))
+				continue
+			}
+			c.morganaStackErrors = append(c.morganaStackErrors, stackError)
+		}
+	}
+	return &c
+}
+
+// copyAnnotations copies annotations together with their metadata.
+func copyAnnotations(annotations []Annotation) []Annotation {
+	if annotations == nil {
+		return nil
+	}
+	out := make([]Annotation, len(annotations))
+	for i, a := range annotations {
+		out[i] = Annotation{Msg: a.Msg, Frame: a.Frame, MetaData: deepCopyMap(a.MetaData)}
+	}
+	return out
+}
+
+func deepCopyMap(input map[string]any) map[string]any {
+	if input == nil {
+		return nil
+	}
+	out := make(map[string]any, len(input))
+	for k, v := range input {
+		out[k] = deepCopyValue(v)
+	}
+	return out
+}
+
+// deepCopyValue copies maps, slices and arrays recursively. Other values,
+// including pointers and the contents of structs, are copied as they are.
+func deepCopyValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		return deepCopyMap(t)
+	case []any:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = deepCopyValue(e)
+		}
+		return out
+	}
+	return deepCopyReflect(reflect.ValueOf(v)).Interface()
+}
+
+func deepCopyReflect(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), deepCopyReflect(iter.Value()))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(deepCopyReflect(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(deepCopyReflect(v.Index(i)))
+		}
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(deepCopyReflect(v.Elem()))
+		return out
+	default:
+		return v
+	}
+}
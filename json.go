@@ -0,0 +1,177 @@
+package morgana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// JSONSchemaVersion is the version of the document written by ToJson and
+// MarshalJSON:
+//
+//	{
+//	  "schemaVersion": 1,
+//	  "id": "...", "type": "...", "with": "...", "msg": "...",
+//	  "statusCode": 400, "customCode": "...", "stackTrace": "...",
+//	  "stackFrames": [{"file": "...", "line": 1, "function": "..."}],
+//	  "metaData": {...},
+//	  "fieldErrors": [{"field": "...", "code": "...", "msg": "..."}],
+//	  "annotations": [{"msg": "...", "frame": {...}, "metaData": {...}}],
+//	  "stackErrors": [ <nested documents of the same shape> ],
+//	  "cause": {"message": "...", "goType": "*fs.PathError"}
+//	}
+//
+// Empty members are omitted. FromJSON also accepts documents without a
+// schemaVersion, such as ToJsonSafe output.
+const JSONSchemaVersion = 1
+
+// RemoteError stands in for a cause decoded from JSON. GoType is the Go type
+// name of the original cause.
+type RemoteError struct {
+	Message string
+	GoType  string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+type jsonCause struct {
+	Message string `json:"message"`
+	GoType  string `json:"goType,omitempty"`
+}
+
+type jsonMorgana struct {
+	SchemaVersion int            `json:"schemaVersion,omitempty"`
+	ID            string         `json:"id,omitempty"`
+	Type          string         `json:"type,omitempty"`
+	With          string         `json:"with,omitempty"`
+	Msg           string         `json:"msg,omitempty"`
+	StatusCode    int            `json:"statusCode,omitempty"`
+	CustomCode    string         `json:"customCode,omitempty"`
+	StackTrace    string         `json:"stackTrace,omitempty"`
+	StackFrames   []StackFrame   `json:"stackFrames,omitempty"`
+	MetaData      map[string]any `json:"metaData,omitempty"`
+	FieldErrors   []FieldError   `json:"fieldErrors,omitempty"`
+	Annotations   []Annotation   `json:"annotations,omitempty"`
+	StackErrors   []*jsonMorgana `json:"stackErrors,omitempty"`
+	Cause         *jsonCause     `json:"cause,omitempty"`
+	// LegacyWith reads the "WithValue" member written before schema version 1.
+	LegacyWith string `json:"WithValue,omitempty"`
+}
+
+func (m *morgana) MarshalJSON() ([]byte, error) {
+	doc := toJSONMorgana(m, make(map[Morgana]struct{}))
+	doc.SchemaVersion = JSONSchemaVersion
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON replaces the content of m with the decoded document. Metadata
+// values come back as the generic encoding/json types. An immutable Morgana
+// is left untouched and an error is returned.
+func (m *morgana) UnmarshalJSON(data []byte) error {
+	if m.immutable {
+		return errors.New("morgana: cannot unmarshal into an immutable Morgana")
+	}
+	var doc jsonMorgana
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.SchemaVersion > JSONSchemaVersion {
+		return fmt.Errorf("morgana: unsupported JSON schema version %d", doc.SchemaVersion)
+	}
+	*m = *fromJSONMorgana(&doc)
+	return nil
+}
+
+// FromJSON decodes a document written by ToJson, MarshalJSON or ToJsonSafe,
+// checking its signature as the policy set with SetSignaturePolicy asks.
+func FromJSON(data []byte) (Morgana, error) {
+	m := &morgana{}
+	if err := m.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return checkSignature(m, data)
+}
+
+func toJSONMorgana(m Morgana, seen map[Morgana]struct{}) *jsonMorgana {
+	seen[m] = struct{}{}
+	defer delete(seen, m)
+
+	doc := &jsonMorgana{
+		ID:          m.GetID(),
+		Type:        m.GetType(),
+		With:        m.GetWith(),
+		Msg:         m.GetMessage(),
+		StatusCode:  m.GetStatusCode(),
+		CustomCode:  m.GetCustomCode(),
+		StackFrames: m.GetStackFrames(),
+		MetaData:    m.GetMetaData(),
+		FieldErrors: m.GetFieldErrors(),
+		Annotations: m.GetAnnotations(),
+	}
+
+	var cause error
+	if mm, ok := m.(*morgana); ok {
+		doc.MetaData = mm.redactMap(mm.MetaData)
+		doc.Annotations = mm.redactAnnotations()
+		doc.StackTrace = mm.StackTrace
+		if mm.cause != nil {
+			cause = rootCause(mm.cause)
+		}
+	}
+	if cause != nil {
+		doc.Cause = &jsonCause{Message: cause.Error(), GoType: fmt.Sprintf("%T", cause)}
+		if remote, ok := cause.(*RemoteError); ok {
+			doc.Cause.GoType = remote.GoType
+		}
+	}
+
+	for _, stackError := range m.GetMorganaStackErrors() {
+		if _, cycle := seen[stackError]; cycle {
+			continue
+		}
+		doc.StackErrors = append(doc.StackErrors, toJSONMorgana(stackError, seen))
+	}
+	return doc
+}
+
+func fromJSONMorgana(doc *jsonMorgana) *morgana {
+	with := doc.With
+	if with == "" {
+		with = doc.LegacyWith
+	}
+	m := &morgana{
+		Type:               doc.Type,
+		WithValue:          with,
+		Msg:                doc.Msg,
+		StatusCode:         doc.StatusCode,
+		CustomCode:         doc.CustomCode,
+		StackTrace:         doc.StackTrace,
+		morganaStackErrors: make([]Morgana, 0, len(doc.StackErrors)),
+		MetaData:           doc.MetaData,
+		StackFrames:        doc.StackFrames,
+		redactedKeys:       make(map[string]struct{}),
+		ID:                 doc.ID,
+		FieldErrors:        doc.FieldErrors,
+		Annotations:        doc.Annotations,
+	}
+	if m.MetaData == nil {
+		m.MetaData = make(map[string]any)
+	}
+	if m.StackFrames == nil {
+		m.StackFrames = make([]StackFrame, 0)
+	}
+	if m.FieldErrors == nil {
+		m.FieldErrors = make([]FieldError, 0)
+	}
+	if doc.Cause != nil {
+		m.cause = &RemoteError{Message: doc.Cause.Message, GoType: doc.Cause.GoType}
+	}
+	for _, stackError := range doc.StackErrors {
+		if stackError != nil {
+			m.morganaStackErrors = append(m.morganaStackErrors, fromJSONMorgana(stackError))
+		}
+	}
+	return m
+}
@@ -0,0 +1,135 @@
+package morgana_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	cause := &net.AddrError{Err: "no route", Addr: "10.0.0.1"}
+	inner := morgana.New("DB").WithCustomCode("DB_DOWN").WithMessage("db down").WithCause(cause)
+	m := morgana.New("API").WithCustomCode("FAILED").WithStatusCode(http.StatusBadGateway).With("orders").
+		WithMessage("request failed").WithAddMetaDataKey("attempt", 3).
+		WithFieldError("id", "required", "missing").WithFullStack(1, 4).
+		WithStackTrace(1).WithError(inner.ToError())
+
+	t.Run("FromJSON", func(t *testing.T) {
+		decoded, err := morgana.FromJSON([]byte(m.ToJson()))
+		require.NoError(t, err)
+
+		assert.Equal(t, m.GetID(), decoded.GetID())
+		assert.Equal(t, "API", decoded.GetType())
+		assert.Equal(t, "orders", decoded.GetWith())
+		assert.Equal(t, http.StatusBadGateway, decoded.GetStatusCode())
+		assert.Equal(t, float64(3), decoded.GetMetaDataKey("attempt"))
+		assert.Equal(t, m.GetFieldErrors(), decoded.GetFieldErrors())
+		assert.Equal(t, m.GetStackFrames(), decoded.GetStackFrames())
+		assert.ErrorIs(t, decoded, m)
+
+		stack := decoded.GetMorganaStackErrors()
+		require.Len(t, stack, 1)
+		assert.Equal(t, inner.GetID(), stack[0].GetID())
+		var remote *morgana.RemoteError
+		require.True(t, errors.As(stack[0].Cause(), &remote))
+		assert.Equal(t, cause.Error(), remote.Message)
+		assert.Equal(t, "*net.AddrError", remote.GoType)
+
+		assert.JSONEq(t, m.ToJson(), decoded.ToJson())
+	})
+
+	t.Run("UnmarshalJSON", func(t *testing.T) {
+		data, err := json.Marshal(map[string]any{"error": m})
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"schemaVersion":1`)
+
+		out := struct {
+			Error morgana.Morgana `json:"error"`
+		}{Error: morgana.New("")}
+		require.NoError(t, json.Unmarshal(data, &out))
+		assert.Equal(t, m.GetID(), out.Error.GetID())
+		assert.Len(t, out.Error.GetMorganaStackErrors(), 1)
+	})
+
+	t.Run("SafeAndLegacyShapes", func(t *testing.T) {
+		decoded, err := morgana.FromJSON([]byte(m.ToJsonSafe()))
+		require.NoError(t, err)
+		assert.Equal(t, "FAILED", decoded.GetCustomCode())
+
+		decoded, err = morgana.FromJSON([]byte(`{"Type":"OLD","WithValue":"w","Msg":"m","StatusCode":400}`))
+		require.NoError(t, err)
+		assert.Equal(t, "OLD", decoded.GetType())
+		assert.Equal(t, "w", decoded.GetWith())
+		assert.Equal(t, http.StatusBadRequest, decoded.GetStatusCode())
+	})
+
+	t.Run("RejectsNewerSchema", func(t *testing.T) {
+		_, err := morgana.FromJSON([]byte(`{"schemaVersion":99}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("RejectsImmutable", func(t *testing.T) {
+		tmpl := morgana.New("Template").WithCustomCode("TPL").Immutable()
+		assert.ErrorContains(t, json.Unmarshal([]byte(m.ToJson()), tmpl), "immutable")
+		assert.Equal(t, "TPL", tmpl.GetCustomCode())
+		assert.True(t, tmpl.IsImmutable())
+	})
+}
+
+func TestProblemDetails(t *testing.T) {
+	m := morgana.New("VALIDATION").WithCustomCode("INVALID_INPUT").WithStatusCode(http.StatusUnprocessableEntity).
+		WithMessage("email is invalid").WithFieldError("email", "format", "bad email").
+		WithAddMetaDataKey("tenant", "t1").WithAddMetaDataKey("token", "secret").WithRedactedKey("token")
+
+	t.Run("ToProblemDetails", func(t *testing.T) {
+		p := m.ToProblemDetails()
+		assert.Equal(t, "about:blank", p.Type)
+		assert.Equal(t, http.StatusText(http.StatusUnprocessableEntity), p.Title)
+		assert.Equal(t, http.StatusUnprocessableEntity, p.Status)
+		assert.Equal(t, "email is invalid", p.Detail)
+		assert.Equal(t, m.GetID(), p.Instance)
+		assert.Equal(t, "INVALID_INPUT", p.Extensions[morgana.ProblemExtCustomCode])
+		assert.Equal(t, map[string]any{"tenant": "t1"}, p.Extensions[morgana.ProblemExtMetaData])
+	})
+
+	t.Run("TypeURI", func(t *testing.T) {
+		morgana.SetProblemTypeBaseURI("https://errors.example.com/")
+		defer morgana.SetProblemTypeBaseURI("")
+		p := m.ToProblemDetails()
+		assert.Equal(t, "https://errors.example.com/VALIDATION", p.Type)
+		assert.Equal(t, "VALIDATION", p.Title)
+	})
+
+	t.Run("WriteHTTPProblemAndParse", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		m.WriteHTTPProblem(rec)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, morgana.ProblemJSONContentType, rec.Header().Get("Content-Type"))
+		assert.NotContains(t, rec.Body.String(), "secret")
+
+		parsed, err := morgana.FromProblemDetails(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "VALIDATION", parsed.GetType())
+		assert.Equal(t, "INVALID_INPUT", parsed.GetCustomCode())
+		assert.Equal(t, http.StatusUnprocessableEntity, parsed.GetStatusCode())
+		assert.Equal(t, "email is invalid", parsed.GetMessage())
+		assert.Equal(t, m.GetID(), parsed.GetID())
+		assert.Equal(t, m.GetFieldErrors(), parsed.GetFieldErrors())
+		assert.Equal(t, "t1", parsed.GetMetaDataKey("tenant"))
+	})
+
+	t.Run("ForeignProblem", func(t *testing.T) {
+		parsed, err := morgana.FromProblemDetails([]byte(`{"type":"https://example.com/out-of-credit","title":"You do not have enough credit.","status":"403","detail":"Your balance is 30"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "You do not have enough credit.", parsed.GetType())
+		assert.Equal(t, 0, parsed.GetStatusCode())
+		assert.Equal(t, "https://example.com/out-of-credit", parsed.GetMetaDataKey(morgana.MetaDataDocsURL))
+	})
+}
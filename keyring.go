@@ -0,0 +1,245 @@
+package morgana
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// KeyRing holds AES keys by ID for RedactEncrypt. Values are sealed with the
+// primary key and opened with whichever key sealed them, so a key is rotated
+// by adding its successor, making that the primary and removing the old key
+// once its tokens no longer need to be read.
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	primary string
+}
+
+// NewKeyRing returns an empty KeyRing.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]cipher.AEAD)}
+}
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Add adds an AES-128, AES-192 or AES-256 key under id. The first key added
+// becomes the primary key.
+func (k *KeyRing) Add(id string, key []byte) error {
+	if !keyIDPattern.MatchString(id) {
+		return fmt.Errorf("morgana: invalid key ID %q", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("morgana: key %q: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("morgana: key %q: %w", id, err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("morgana: key %q is already in the ring", id)
+	}
+	k.keys[id] = aead
+	if k.primary == "" {
+		k.primary = id
+	}
+	return nil
+}
+
+// SetPrimary makes the key id seal new values.
+func (k *KeyRing) SetPrimary(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("morgana: unknown key %q", id)
+	}
+	k.primary = id
+	return nil
+}
+
+// Primary returns the ID of the key sealing new values.
+func (k *KeyRing) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Remove drops the key id and reports whether it was in the ring. Removing
+// the primary key leaves the ring unable to encrypt until SetPrimary.
+func (k *KeyRing) Remove(id string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return false
+	}
+	delete(k.keys, id)
+	if k.primary == id {
+		k.primary = ""
+	}
+	return true
+}
+
+const encryptedPrefix = "[enc:"
+
+var encryptedPattern = regexp.MustCompile(`\[enc:([A-Za-z0-9._-]+):([A-Za-z0-9_-]+)\]`)
+
+// Encrypt seals the JSON encoding of value with the primary key and returns
+// it as "[enc:<key ID>:<base64url nonce and ciphertext>]".
+func (k *KeyRing) Encrypt(value any) (string, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	k.mu.RLock()
+	id, aead := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+	if aead == nil {
+		return "", fmt.Errorf("morgana: key ring has no primary key")
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(id))
+	return encryptedPrefix + id + ":" + base64.RawURLEncoding.EncodeToString(sealed) + "]", nil
+}
+
+// Decrypt opens a token written by Encrypt and returns the decoded value,
+// with JSON numbers as json.Number.
+func (k *KeyRing) Decrypt(token string) (any, error) {
+	match := encryptedPattern.FindStringSubmatch(token)
+	if match == nil || match[0] != token {
+		return nil, fmt.Errorf("morgana: not an encrypted value")
+	}
+	plaintext, err := k.open(match[1], match[2])
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONValue(plaintext)
+}
+
+func (k *KeyRing) open(id, data string) ([]byte, error) {
+	k.mu.RLock()
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, fmt.Errorf("morgana: unknown key %q", id)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("morgana: malformed value sealed with key %q", id)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("morgana: value sealed with key %q: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// RedactEncrypt shows redacted values encrypted with ring, so output stays
+// shareable while holders of the key can recover the values with DecryptJSON
+// or the morgana decrypt command. Output sent to HTTP clients, through
+// WriteHTTP, WriteHTTPRequest in safe mode and problem details, shows
+// RedactedValue instead. Values that cannot be encrypted show RedactedValue.
+func RedactEncrypt(ring *KeyRing) RedactStrategy {
+	return func(value any) string {
+		token, err := ring.Encrypt(value)
+		if err != nil {
+			return RedactedValue
+		}
+		return token
+	}
+}
+
+// DecryptJSON replaces every value encrypted by RedactEncrypt in the JSON
+// document data with the original value. A string holding only a token
+// becomes the original JSON value; tokens inside longer strings become its
+// text. Values the ring cannot decrypt, for example because their key ID is
+// unknown, are left as they are, so one missing key does not hide the rest
+// of the document. An error is returned only for invalid JSON.
+func DecryptJSON(ring *KeyRing, data []byte) ([]byte, error) {
+	doc, err := decodeJSONValue(data)
+	if err != nil {
+		return nil, err
+	}
+	doc = decryptValue(ring, doc)
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// decryptValue restores the tokens in v that ring can decrypt and leaves the
+// others in place.
+func decryptValue(ring *KeyRing, v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for key, value := range t {
+			t[key] = decryptValue(ring, value)
+		}
+	case []any:
+		for i, value := range t {
+			t[i] = decryptValue(ring, value)
+		}
+	case string:
+		if !strings.Contains(t, encryptedPrefix) {
+			return t
+		}
+		if loc := encryptedPattern.FindStringIndex(t); loc != nil && loc[0] == 0 && loc[1] == len(t) {
+			if out, err := ring.Decrypt(t); err == nil {
+				return out
+			}
+			return t
+		}
+		return encryptedPattern.ReplaceAllStringFunc(t, func(token string) string {
+			match := encryptedPattern.FindStringSubmatch(token)
+			plaintext, err := ring.open(match[1], match[2])
+			if err != nil {
+				return token
+			}
+			var s string
+			if json.Unmarshal(plaintext, &s) == nil {
+				return s
+			}
+			return string(plaintext)
+		})
+	}
+	return v
+}
+
+func decodeJSONValue(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// isEncrypted reports whether s holds a value written by RedactEncrypt.
+func isEncrypted(s string) bool {
+	return strings.Contains(s, encryptedPrefix) && encryptedPattern.MatchString(s)
+}
+
+// hideEncrypted replaces the values written by RedactEncrypt in s with
+// RedactedValue.
+func hideEncrypted(s string) string {
+	if !strings.Contains(s, encryptedPrefix) {
+		return s
+	}
+	return encryptedPattern.ReplaceAllString(s, RedactedValue)
+}
@@ -0,0 +1,107 @@
+package morgana_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRing(t *testing.T) {
+	ring := morgana.NewKeyRing()
+	require.NoError(t, ring.Add("2024-01", bytes.Repeat([]byte{7}, 32)))
+	assert.Error(t, ring.Add("2024-01", bytes.Repeat([]byte{7}, 32)))
+	assert.Error(t, ring.Add("bad:id", bytes.Repeat([]byte{7}, 32)))
+	assert.Error(t, ring.Add("short", []byte("short")))
+
+	token, err := ring.Encrypt(map[string]any{"card": "4111"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "[enc:2024-01:"))
+
+	value, err := ring.Decrypt(token)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"card": "4111"}, value)
+
+	t.Run("Rotation", func(t *testing.T) {
+		require.NoError(t, ring.Add("2024-02", bytes.Repeat([]byte{8}, 16)))
+		require.NoError(t, ring.SetPrimary("2024-02"))
+		rotated, err := ring.Encrypt("x")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rotated, "[enc:2024-02:"))
+		_, err = ring.Decrypt(token)
+		assert.NoError(t, err, "old tokens stay readable")
+
+		assert.True(t, ring.Remove("2024-01"))
+		_, err = ring.Decrypt(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		tampered := token[:len(token)-3] + "AA]"
+		_, err := ring.Decrypt(tampered)
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptedRedaction(t *testing.T) {
+	ring := morgana.NewKeyRing()
+	require.NoError(t, ring.Add("k1", bytes.Repeat([]byte{3}, 32)))
+	require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{
+		Strategies: []morgana.KeyStrategy{{Key: "*token*", Strategy: morgana.RedactEncrypt(ring)}},
+	}))
+	defer func() { require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{})) }()
+	require.NoError(t, morgana.RegisterDetector(morgana.EmailDetector, morgana.RedactEncrypt(ring).Replacement()))
+	defer morgana.UnregisterDetector(morgana.EmailDetector.Name())
+
+	m := morgana.New("AUTH").WithStatusCode(http.StatusUnauthorized).
+		WithMessage("login failed for jane@example.com").
+		WithAddMetaDataKey("session_token", "s3cr3t")
+
+	shared := m.ToJsonSafe()
+	assert.NotContains(t, shared, "s3cr3t")
+	assert.NotContains(t, shared, "jane@example.com")
+	assert.Equal(t, 2, strings.Count(shared, "[enc:k1:"), "values are encrypted once")
+
+	decrypted, err := morgana.DecryptJSON(ring, []byte(shared))
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(decrypted, &doc))
+	assert.Equal(t, "login failed for jane@example.com", doc["msg"])
+	assert.Equal(t, "s3cr3t", doc["metaData"].(map[string]any)["session_token"])
+
+	t.Run("UnknownKeyLeftInPlace", func(t *testing.T) {
+		other := morgana.NewKeyRing()
+		require.NoError(t, other.Add("k9", bytes.Repeat([]byte{9}, 32)))
+		foreign, err := other.Encrypt("other secret")
+		require.NoError(t, err)
+		mixed := strings.Replace(shared, `"msg":"`, `"foreign":"`+foreign+`","note":"see `+foreign+`","msg":"`, 1)
+
+		decrypted, err := morgana.DecryptJSON(ring, []byte(mixed))
+		require.NoError(t, err)
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(decrypted, &doc))
+		assert.Equal(t, foreign, doc["foreign"])
+		assert.Equal(t, "see "+foreign, doc["note"])
+		assert.Equal(t, "login failed for jane@example.com", doc["msg"])
+		assert.Equal(t, "s3cr3t", doc["metaData"].(map[string]any)["session_token"])
+	})
+
+	t.Run("PublicOutputUnchanged", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		m.WriteHTTP(rec, true)
+		assert.NotContains(t, rec.Body.String(), "[enc:")
+		assert.Contains(t, rec.Body.String(), `"session_token":"[REDACTED]"`)
+
+		rec = httptest.NewRecorder()
+		m.WriteHTTPRequest(rec, httptest.NewRequest(http.MethodGet, "/", nil), morgana.HTTPOptions{Safe: true})
+		assert.NotContains(t, rec.Body.String(), "[enc:")
+
+		assert.NotContains(t, m.ToProblemDetails().Detail, "[enc:")
+	})
+}
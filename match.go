@@ -0,0 +1,95 @@
+package morgana
+
+import "sync/atomic"
+
+// MatchMode selects which parts of a Morgana's identity errors.Is compares.
+type MatchMode int
+
+const (
+	// MatchDefault defers to the mode set with SetDefaultMatchMode.
+	MatchDefault MatchMode = iota
+	// MatchExact requires Type, CustomCode and With to be equal.
+	MatchExact
+	// MatchCode compares the CustomCode only.
+	MatchCode
+	// MatchType compares the Type only.
+	MatchType
+)
+
+// matchKind compares Type and CustomCode. It is the mode of a Definition, so
+// every instance matches it whatever With value the call set.
+const matchKind MatchMode = -1
+
+var defaultMatchMode atomic.Int32
+
+func init() {
+	defaultMatchMode.Store(int32(MatchExact))
+}
+
+// SetDefaultMatchMode sets the mode used when neither side of a comparison
+// chose one with WithMatchMode. MatchDefault resets it to MatchExact.
+func SetDefaultMatchMode(mode MatchMode) {
+	if mode == MatchDefault {
+		mode = MatchExact
+	}
+	defaultMatchMode.Store(int32(mode))
+}
+
+func (m *morgana) WithMatchMode(mode MatchMode) Morgana {
+	c := m.edit()
+	c.matchMode = mode
+	return c
+}
+
+func (m *morgana) GetMatchMode() MatchMode {
+	return m.matchMode
+}
+
+// Is reports whether target carries a Morgana with the same identity. The
+// target's match mode wins over the receiver's, which wins over the package
+// default. Targets that carry no Morgana never match; errors.Is then keeps
+// looking through the cause chain.
+func (m *morgana) Is(target error) bool {
+	t := morganaOf(target)
+	if t == nil {
+		return false
+	}
+	if t == m {
+		return true
+	}
+
+	mode := t.matchMode
+	if mode == MatchDefault {
+		mode = m.matchMode
+	}
+	if mode == MatchDefault {
+		mode = MatchMode(defaultMatchMode.Load())
+	}
+
+	switch mode {
+	case MatchCode:
+		return t.CustomCode != "" && t.CustomCode == m.CustomCode
+	case MatchType:
+		return t.Type != "" && t.Type == m.Type
+	case matchKind:
+		return t.CustomCode == m.CustomCode && t.Type == m.Type
+	default:
+		return t.CustomCode == m.CustomCode && t.WithValue == m.WithValue && t.Type == m.Type
+	}
+}
+
+// morganaOf returns the Morgana err itself carries, without looking further
+// down its chain.
+func morganaOf(err error) *morgana {
+	switch e := err.(type) {
+	case *morgana:
+		return e
+	case *empo:
+		if m, ok := e.details[morgana_key_data].(*morgana); ok {
+			return m
+		}
+	case *Definition:
+		return e.proto
+	}
+	return nil
+}
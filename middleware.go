@@ -0,0 +1,164 @@
+package morgana
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Metadata keys set by Handler and Middleware.
+const (
+	MetaDataHTTPMethod = "http_method"
+	MetaDataHTTPRoute  = "http_route"
+)
+
+// HandlerFunc is an HTTP handler that reports failures by returning an error.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServerOptions configures Handler and Middleware.
+type ServerOptions struct {
+	// Unsafe reports whether the full error, rather than the safe JSON, may
+	// be written for a request. Redacted keys stay masked in both. Nil always
+	// writes the safe JSON.
+	Unsafe func(r *http.Request, m Morgana) bool
+	// OnError is called with every error before it is written, for example
+	// to log it.
+	OnError func(r *http.Request, m Morgana)
+}
+
+// DefaultServerOptions is used by the package-level Handler and Middleware.
+var DefaultServerOptions = ServerOptions{}
+
+// Handler adapts an error-returning handler using DefaultServerOptions.
+func Handler(fn HandlerFunc) http.Handler {
+	return DefaultServerOptions.Handler(fn)
+}
+
+// Middleware recovers panics in next using DefaultServerOptions.
+func Middleware(next http.Handler) http.Handler {
+	return DefaultServerOptions.Middleware(next)
+}
+
+// Handler adapts fn to http.Handler. A returned error or a panic is
+// converted with FromError or FromPanic, enriched with the request's context
+// fields and trace, method, route and request ID, and written with WriteHTTP.
+// A returned error carrying no Morgana is written as a 500 with a generic
+// message and kept as the cause.
+// Metadata keys the error already has are never overwritten.
+func (o ServerOptions) Handler(fn HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer o.recover(rw, r)
+		if err := fn(rw, r); err != nil {
+			o.writeError(rw, r, handlerError(err))
+		}
+	})
+}
+
+// Middleware recovers panics in next and writes them like Handler does.
+func (o ServerOptions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer o.recover(rw, r)
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (o ServerOptions) recover(rw *responseWriter, r *http.Request) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	if p == http.ErrAbortHandler {
+		panic(p)
+	}
+	o.writeError(rw, r, FromPanic(p))
+}
+
+// handlerError converts an error returned by a handler. An error carrying no
+// Morgana and naming no catalog code becomes a GENERAL 500 with a generic
+// message, so its text never reaches the client; it is kept as the cause for
+// OnError and the full output.
+func handlerError(err error) Morgana {
+	if GetMorgana(err) == nil {
+		if _, ok := DefaultCatalog.Lookup(err.Error()); !ok {
+			return New("GENERAL").WithStatusCode(http.StatusInternalServerError).
+				WithMessage(http.StatusText(http.StatusInternalServerError)).WithCause(err)
+		}
+	}
+	return FromError(err)
+}
+
+func (o ServerOptions) writeError(rw *responseWriter, r *http.Request, m Morgana) {
+	// Never modify a Morgana the handler may share with others.
+	m = m.DeepClone().WithContext(r.Context())
+	for k, v := range requestMetaData(r) {
+		if !m.HasMetaDataKey(k) {
+			m = m.WithAddMetaDataKey(k, v)
+		}
+	}
+	if o.OnError != nil {
+		o.OnError(r, m)
+	}
+	if rw.wroteHeader {
+		return
+	}
+	safe := o.Unsafe == nil || !o.Unsafe(r, m)
+	m.WriteHTTP(rw, safe)
+}
+
+func requestMetaData(r *http.Request) map[string]any {
+	route := r.Pattern
+	if route == "" && r.URL != nil {
+		route = r.URL.Path
+	}
+	md := map[string]any{
+		MetaDataHTTPMethod: r.Method,
+		MetaDataHTTPRoute:  route,
+	}
+	if _, ok := RequestIDFromContext(r.Context()); !ok {
+		if id := r.Header.Get(RequestIDHeader); id != "" {
+			md[MetaDataRequestID] = id
+		}
+	}
+	return md
+}
+
+// responseWriter records whether the response was started so an error is
+// not written over it.
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the underlying writer. A flushed response counts as
+// started.
+func (w *responseWriter) Flush() {
+	w.wroteHeader = true
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack forwards to the underlying writer, or returns an error wrapping
+// http.ErrNotSupported. A hijacked connection counts as started.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
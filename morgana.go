@@ -6,13 +6,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"runtime"
+	"slices"
 	"strings"
 
 	"errors"
-
-	perror "github.com/pkg/errors"
 )
 
 const (
@@ -20,6 +20,8 @@ const (
 )
 
 type Morgana interface {
+	error
+
 	WithStatusCode(statusCode int) Morgana
 	WithCustomCode(customCode string) Morgana
 	WithType(ref string) Morgana
@@ -58,14 +60,32 @@ type Morgana interface {
 	WriteHTTP(w http.ResponseWriter, safe bool)
 	ToFields() map[string]any
 	WithTrace(ctx context.Context) Morgana
+	WithContext(ctx context.Context) Morgana
 	WithID(id string) Morgana
 	GetID() string
 	WithFieldError(field string, code string, msg string) Morgana
 	GetFieldErrors() []FieldError
+	WithAnnotation(skip int, msg string, metaData map[string]any) Morgana
+	GetAnnotations() []Annotation
 
 	// gRPC helpers
 	ToGRPCCode() int
 	FromGRPCCode(code int) Morgana
+
+	// Immutability and copying
+	Immutable() Morgana
+	IsImmutable() bool
+	DeepClone() Morgana
+
+	// errors.Is matching
+	Is(err error) bool
+	WithMatchMode(mode MatchMode) Morgana
+	GetMatchMode() MatchMode
+
+	// RFC 9457 problem details
+	ToProblemDetails() ProblemDetails
+	WriteHTTPProblem(w http.ResponseWriter)
+	WriteHTTPRequest(w http.ResponseWriter, r *http.Request, opts HTTPOptions)
 }
 
 type StackFrame struct {
@@ -94,32 +114,51 @@ type morgana struct {
 	redactedKeys map[string]struct{}
 	ID           string
 	FieldErrors  []FieldError
+	Annotations  []Annotation
 	cause        error
+	immutable    bool
+	matchMode    MatchMode
+	// detectors and public are set on the copies made by safeView.
+	detectors []detectorRule
+	public    bool
 }
 
+// GetMorganaStackErrors returns the stack errors. For an immutable Morgana
+// the slice is a copy.
 func (m *morgana) GetMorganaStackErrors() []Morgana {
+	if m.immutable {
+		return slices.Clone(m.morganaStackErrors)
+	}
 	return m.morganaStackErrors
 }
 
 func (m *morgana) WithAddMetaDataKey(key string, value any) Morgana {
-	if m.MetaData == nil {
-		m.MetaData = make(map[string]any)
+	c := m.edit()
+	if m.immutable {
+		c.MetaData = maps.Clone(c.MetaData)
 	}
-	m.MetaData[key] = value
-	return m
+	if c.MetaData == nil {
+		c.MetaData = make(map[string]any)
+	}
+	c.MetaData[key] = value
+	return c
 }
 
 func (m *morgana) WithAddMetaData(md map[string]any) Morgana {
 	if md == nil {
 		return m
 	}
-	if m.MetaData == nil {
-		m.MetaData = make(map[string]any)
+	c := m.edit()
+	if m.immutable {
+		c.MetaData = maps.Clone(c.MetaData)
+	}
+	if c.MetaData == nil {
+		c.MetaData = make(map[string]any)
 	}
 	for k, v := range md {
-		m.MetaData[k] = v
+		c.MetaData[k] = v
 	}
-	return m
+	return c
 }
 
 func (m *morgana) GetMetaDataKey(key string) any {
@@ -128,6 +167,9 @@ func (m *morgana) GetMetaDataKey(key string) any {
 	}
 
 	if val, ok := m.MetaData[key]; ok {
+		if m.immutable {
+			return deepCopyValue(val)
+		}
 		return val
 	}
 
@@ -142,7 +184,12 @@ func (m *morgana) HasMetaDataKey(key string) bool {
 	return ok
 }
 
+// GetMetaData returns the metadata. For an immutable Morgana the map and the
+// maps and slices nested in it are copies.
 func (m *morgana) GetMetaData() map[string]any {
+	if m.immutable {
+		return deepCopyMap(m.MetaData)
+	}
 	return m.MetaData
 }
 
@@ -186,6 +233,18 @@ func (m *morgana) String() string {
 		builder.WriteString(fmt.Sprintf("%v\n", " , -----------------------------------------------------------"))
 	}
 
+	if len(m.Annotations) != 0 {
+		builder.WriteString("Annotations: \n")
+		for i, a := range m.Annotations {
+			builder.WriteString(fmt.Sprintf("[%d] %s (%s:%d %s)", i, a.Msg, a.Frame.File, a.Frame.Line, a.Frame.Function))
+			if len(a.MetaData) != 0 {
+				builder.WriteString(fmt.Sprintf(" %+#v", m.redactMap(a.MetaData)))
+			}
+			builder.WriteString("\n")
+		}
+		builder.WriteString(fmt.Sprintf("%v\n", " , -----------------------------------------------------------"))
+	}
+
 	if len(m.morganaStackErrors) != 0 {
 		builder.WriteString("MorganaStackErrors: \n")
 		builder.WriteString(fmt.Sprintf("%v", m.morganaStackErrors))
@@ -193,7 +252,7 @@ func (m *morgana) String() string {
 	}
 
 	if len(m.MetaData) != 0 {
-		builder.WriteString(fmt.Sprintf("MetaData: %+#v\n", m.MetaData))
+		builder.WriteString(fmt.Sprintf("MetaData: %+#v\n", m.redactMap(m.MetaData)))
 		builder.WriteString(fmt.Sprintf("%v\n", " , -----------------------------------------------------------"))
 	}
 
@@ -244,7 +303,7 @@ func (m *morgana) stringSimple() string {
 	}
 
 	if len(m.MetaData) != 0 {
-		builder.WriteString(fmt.Sprintf("MetaData: %v\n", m.MetaData))
+		builder.WriteString(fmt.Sprintf("MetaData: %v\n", m.redactMap(m.MetaData)))
 		builder.WriteString(fmt.Sprintf("%v", " , "))
 	}
 
@@ -257,55 +316,115 @@ func (m *morgana) stringSimple() string {
 func (m *morgana) ToError() error {
 
 	emp := NewEmpo(m.stringSimple()).WithAttributes(map[string]any{morgana_key_data: m})
-	if mCause := m.Cause(); mCause != nil {
-		emp = emp.WithCause(mCause)
+	if m.cause != nil {
+		emp = emp.WithCause(m.cause)
 	}
 	return emp.ToError()
 }
 
+// Error implements the error interface with the same text as ToError().Error().
+func (m *morgana) Error() string {
+	return m.stringSimple()
+}
+
+// Unwrap exposes the cause and every stack error to errors.Is and errors.As.
+func (m *morgana) Unwrap() []error {
+	return m.unwrapAll()
+}
+
+// unwrapAll flattens the Morgana tree below m into a single list: the cause of
+// each Morgana followed by its stack errors, depth first. Every Morgana is
+// listed once as a stackLink, which does not unwrap any further, so errors.Is
+// and errors.As terminate even when the tree contains cycles.
+func (m *morgana) unwrapAll() []error {
+	var errs []error
+	seen := map[*morgana]bool{m: true}
+	var walk func(n *morgana)
+	add := func(err error) {
+		mor := morganaOf(err)
+		if mor == nil {
+			errs = append(errs, err)
+			return
+		}
+		if seen[mor] {
+			return
+		}
+		seen[mor] = true
+		errs = append(errs, stackLink{mor})
+		walk(mor)
+	}
+	walk = func(n *morgana) {
+		if n.cause != nil {
+			add(n.cause)
+		}
+		for _, stackError := range n.morganaStackErrors {
+			add(stackError)
+		}
+	}
+	walk(m)
+	return errs
+}
+
+// stackLink presents a Morgana found below another one to the errors package.
+// It matches and converts like the Morgana but hides its children, which
+// unwrapAll has already listed.
+type stackLink struct {
+	m *morgana
+}
+
+func (l stackLink) Error() string {
+	return l.m.Error()
+}
+
+func (l stackLink) Is(target error) bool {
+	return l.m.Is(target)
+}
+
+func (l stackLink) As(target any) bool {
+	if t, ok := target.(*Morgana); ok {
+		*t = l.m
+		return true
+	}
+	return false
+}
+
+// FromError returns the first Morgana found in err's chain, or a GENERAL
+// Morgana describing err when the chain holds none. A *MultiError in the
+// chain, wrapped or not, is converted with ToMorgana. A Morgana carrying only a
+// CustomCode registered in DefaultCatalog, or a foreign error whose message
+// is such a code, is completed from the catalog entry.
 func FromError(err error) Morgana {
 	if err == nil {
 		return nil
 	}
 
-	empoData := GetEmpo(err)
-	if empoData != nil {
-
-		if val, ok := empoData.GetAttributes()[morgana_key_data]; ok {
+	if morgana := GetMorgana(err); morgana != nil {
+		return DefaultCatalog.enrich(morgana)
+	}
 
-			if morgana, ok := val.(Morgana); ok {
-				return morgana
-			}
-		}
+	if _, ok := DefaultCatalog.Lookup(err.Error()); ok {
+		return DefaultCatalog.instance(err.Error(), nil, 4).WithCause(err)
 	}
 
 	return New("GENERAL").WithStatusCode(http.StatusNotImplemented).WithMessage(err.Error()).WithCause(err)
 }
 
+// GetMorgana returns the first Morgana found in err's chain, or nil. Errors
+// wrapped with fmt.Errorf("%w") or similar are traversed, and a *MultiError
+// yields its ToMorgana aggregate.
 func GetMorgana(err error) Morgana {
 	if err == nil {
 		return nil
 	}
 
-	empoData := GetEmpo(err)
-	if empoData != nil {
-
-		if val, ok := empoData.GetAttributes()[morgana_key_data]; ok {
-
-			if morgana, ok := val.(Morgana); ok {
-				return morgana
-			}
-		}
+	var morgana Morgana
+	if errors.As(err, &morgana) {
+		return morgana
 	}
 
 	return nil
 }
 
-func (m *morgana) Is(err error) bool {
-	morganaError := FromError(err)
-	return (morganaError.GetCustomCode() == m.GetCustomCode()) && (m.GetWith() == morganaError.GetWith()) && (m.GetType() == morganaError.GetType())
-}
-
 func New(typeValue string) Morgana {
 	mor := &morgana{Type: typeValue, morganaStackErrors: make([]Morgana, 0), MetaData: make(map[string]any), StackFrames: make([]StackFrame, 0), redactedKeys: make(map[string]struct{}), FieldErrors: make([]FieldError, 0)}
 	//mor.WithStackTrace(3)
@@ -314,39 +433,12 @@ func New(typeValue string) Morgana {
 	return mor
 }
 
+// Clone returns a mutable deep copy of the Morgana whose single-frame stack
+// trace is recaptured at stackLevel. Use DeepClone to keep the original trace.
 func (m *morgana) Clone(stackLevel int) Morgana {
-
-	e := New(m.Type).WithCustomCode(m.CustomCode).
-		WithStatusCode(m.StatusCode).WithMessage(m.Msg).With(m.WithValue).WithStackTrace(stackLevel)
-
-	// deep-copy metadata
-	if len(m.MetaData) != 0 {
-		md := make(map[string]any, len(m.MetaData))
-		for k, v := range m.MetaData {
-			md[k] = v
-		}
-		e = e.WithAddMetaData(md)
-	}
-
-	// copy redacted keys
-	if mm, ok := e.(*morgana); ok {
-		mm.StackFrames = append(mm.StackFrames[:0:0], m.StackFrames...)
-		mm.FieldErrors = append(mm.FieldErrors[:0:0], m.FieldErrors...)
-		for k := range m.redactedKeys {
-			if mm.redactedKeys == nil {
-				mm.redactedKeys = make(map[string]struct{})
-			}
-			mm.redactedKeys[k] = struct{}{}
-		}
-		mm.ID = m.ID
-		mm.cause = m.cause
-	}
-
-	for _, stackError := range m.morganaStackErrors {
-		e = e.WithError(stackError.ToError())
-	}
-
-	return e
+	c := m.deepCopy(false)
+	c.WithStackTrace(stackLevel)
+	return c
 }
 
 func (m *morgana) WithStackTrace(skip int) Morgana {
@@ -356,9 +448,10 @@ func (m *morgana) WithStackTrace(skip int) Morgana {
 	frames := runtime.CallersFrames(pc[:n])
 	frame, _ := frames.Next()
 
-	m.StackTrace = fmt.Sprintf("%s:%d %s\n", frame.File, frame.Line, frame.Function)
+	c := m.edit()
+	c.StackTrace = fmt.Sprintf("%s:%d %s\n", frame.File, frame.Line, frame.Function)
 
-	return m
+	return c
 
 }
 
@@ -369,25 +462,33 @@ func (m *morgana) WithFullStack(skip int, maxFrames int) Morgana {
 	pc := make([]uintptr, maxFrames)
 	n := runtime.Callers(skip, pc)
 	frames := runtime.CallersFrames(pc[:n])
-	m.StackFrames = m.StackFrames[:0]
+	stackFrames := make([]StackFrame, 0, n)
 	for {
 		frame, more := frames.Next()
-		m.StackFrames = append(m.StackFrames, StackFrame{File: frame.File, Line: frame.Line, Function: frame.Function})
+		stackFrames = append(stackFrames, StackFrame{File: frame.File, Line: frame.Line, Function: frame.Function})
 		if !more {
 			break
 		}
 	}
-	return m
+	c := m.edit()
+	c.StackFrames = stackFrames
+	return c
 }
 
+// GetStackFrames returns the stack frames. For an immutable Morgana the
+// slice is a copy.
 func (m *morgana) GetStackFrames() []StackFrame {
+	if m.immutable {
+		return slices.Clone(m.StackFrames)
+	}
 	return m.StackFrames
 }
 
 func (m *morgana) WithMessage(msg string, args ...string) Morgana {
 	r := strings.NewReplacer(args...)
-	m.Msg = r.Replace(msg)
-	return m
+	c := m.edit()
+	c.Msg = r.Replace(msg)
+	return c
 }
 
 func (m *morgana) GetMessage() string {
@@ -397,23 +498,27 @@ func (m *morgana) GetMessage() string {
 
 func (m *morgana) WithStatusCode(statusCode int) Morgana {
 
-	m.StatusCode = statusCode
-	return m
+	c := m.edit()
+	c.StatusCode = statusCode
+	return c
 }
 
 func (m *morgana) WithCustomCode(customCode string) Morgana {
-	m.CustomCode = customCode
-	return m
+	c := m.edit()
+	c.CustomCode = customCode
+	return c
 }
 
 func (m *morgana) WithType(ref string) Morgana {
-	m.Type = ref
-	return m
+	c := m.edit()
+	c.Type = ref
+	return c
 }
 func (m *morgana) With(value string) Morgana {
 
-	m.WithValue = value
-	return m
+	c := m.edit()
+	c.WithValue = value
+	return c
 }
 
 func (m *morgana) WithError(err error) Morgana {
@@ -422,27 +527,50 @@ func (m *morgana) WithError(err error) Morgana {
 		return m
 	}
 
-	// If the error carries a Morgana, attach it to the stack
+	c := m.edit()
+	if m.immutable {
+		c.morganaStackErrors = slices.Clip(c.morganaStackErrors)
+	}
+	c.attachError(err)
+	return c
+
+}
+
+// attachError pushes err onto the stack errors of m in place. Every member
+// of a joined error (Unwrap() []error, such as errors.Join) is attached.
+func (m *morgana) attachError(err error) {
+	if err == nil {
+		return
+	}
+
+	// If the error itself is a Morgana, attach it to the stack
+	if mor := morganaOf(err); mor != nil {
+		m.morganaStackErrors = append(m.morganaStackErrors, mor)
+		return
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			m.attachError(e)
+		}
+		return
+	}
+
+	// If the error carries a Morgana further down, attach it to the stack
 	if mor := GetMorgana(err); mor != nil {
 		m.morganaStackErrors = append(m.morganaStackErrors, mor)
-		return m
+		return
 	}
 
 	// Otherwise, traverse unwrap chain and convert each into a Morgana stack error
-	for e := err; e != nil; e = errors.Unwrap(e) {
-		if mor := GetMorgana(e); mor != nil {
-			m.morganaStackErrors = append(m.morganaStackErrors, mor)
-			continue
-		}
+	for e := err; e != nil; e = unwrapCause(e) {
 		// Create a lightweight Morgana for this error without touching parent InternalDetail
-		child := New("GENERAL").WithMessage(e.Error())
+		// and keep the link itself, with its chain, as the child's cause.
+		child := New("GENERAL").WithMessage(e.Error()).WithCause(e)
 		m.morganaStackErrors = append(m.morganaStackErrors, child)
 		// Optionally record cause for chain traversal
-		m.WithCause(e)
+		m.setCause(e)
 	}
-
-	return m
-
 }
 
 func (m *morgana) WithCause(err error) Morgana {
@@ -452,32 +580,88 @@ func (m *morgana) WithCause(err error) Morgana {
 	if m.cause != nil {
 		return m
 	}
-	root := err
+	c := m.edit()
+	c.setCause(err)
+	return c
+}
+
+// setCause records err, with its whole chain, as the cause unless one is
+// already set. It always modifies m in place.
+func (m *morgana) setCause(err error) {
+	if m.cause != nil {
+		return
+	}
+	m.cause = err
+}
+
+// rootCause follows err's single-error unwrap path to its end. It stops when
+// the path comes back to a Morgana it already passed.
+func rootCause(err error) error {
+	seen := map[*morgana]bool{}
 	for {
-		u := errors.Unwrap(root)
+		if mor := morganaOf(err); mor != nil {
+			if seen[mor] {
+				return err
+			}
+			seen[mor] = true
+		}
+		u := unwrapCause(err)
 		if u == nil {
-			break
+			return err
 		}
-		root = u
+		err = u
 	}
-	m.cause = root
-	return m
 }
 
-func (m *morgana) Cause() error {
-	if m.cause != nil {
-		return m.cause
+// unwrapCause is the single-error unwrap path: errors.Unwrap, except that a
+// Morgana or an error from ToError, whose Unwrap returns a slice, yields its
+// cause.
+func unwrapCause(err error) error {
+	if u := errors.Unwrap(err); u != nil {
+		return u
 	}
-	if len(m.morganaStackErrors) != 0 {
-		last := m.morganaStackErrors[len(m.morganaStackErrors)-1]
-		if c := last.Cause(); c != nil {
-			return c
-		}
-		return last.ToError()
+	if mor := morganaOf(err); mor != nil {
+		return mor.cause
+	}
+	if e, ok := err.(*empo); ok {
+		return e.cause
 	}
 	return nil
 }
 
+// Cause returns the root of the recorded cause's chain. Without one it
+// follows the last stack error down, returning the first cause found or else
+// the deepest last stack error, and stops when the stack errors loop back.
+// The recorded cause itself, with every link of its chain, stays reachable
+// through errors.Is and errors.As.
+func (m *morgana) Cause() error {
+	seen := map[*morgana]bool{}
+	for n := m; ; {
+		if n.cause != nil {
+			return rootCause(n.cause)
+		}
+		if len(n.morganaStackErrors) == 0 {
+			if n == m {
+				return nil
+			}
+			return n.ToError()
+		}
+		seen[n] = true
+		last := n.morganaStackErrors[len(n.morganaStackErrors)-1]
+		next, ok := last.(*morgana)
+		if !ok {
+			if c := last.Cause(); c != nil {
+				return c
+			}
+			return last.ToError()
+		}
+		if seen[next] {
+			return next.ToError()
+		}
+		n = next
+	}
+}
+
 func (m *morgana) GetWithStackTrace() string {
 
 	return m.StackTrace
@@ -500,46 +684,6 @@ func (m *morgana) GetWith() string {
 	return m.WithValue
 }
 
-func Wrap(err error, err2 error) error {
-	if err == nil && err2 == nil {
-		return nil
-	}
-
-	err1Morgana := GetMorgana(err)
-	err2Morgana := GetMorgana(err2)
-
-	if err1Morgana == nil && err2Morgana == nil {
-		if err != nil && err2 != nil {
-
-			return perror.Wrap(err2, err.Error())
-		}
-
-		if err != nil && err2 == nil {
-			return err
-		}
-
-		if err == nil && err2 != nil {
-			return err2
-		}
-
-	}
-
-	if err1Morgana != nil && err2Morgana == nil {
-		err1Morgana.WithError(err2).WithCause(err)
-		return err1Morgana.ToError()
-	}
-	if err1Morgana == nil && err2Morgana != nil {
-		err2Morgana.WithError(err).WithCause(err2)
-		return err2Morgana.ToError()
-	}
-	if err1Morgana != nil && err2Morgana != nil {
-		err1Morgana.WithError(err2).WithCause(err)
-		return err1Morgana.ToError()
-	}
-
-	return nil
-}
-
 func GetStringDetail(err error) string {
 
 	if err == nil {
@@ -557,26 +701,15 @@ func GetStringDetail(err error) string {
 // -------- New helper functionality --------
 
 func (m *morgana) WithRedactedKey(key string) Morgana {
-	if m.redactedKeys == nil {
-		m.redactedKeys = make(map[string]struct{})
-	}
-	m.redactedKeys[key] = struct{}{}
-	return m
-}
-
-func (m *morgana) redactMap(input map[string]any) map[string]any {
-	if input == nil {
-		return nil
+	c := m.edit()
+	if m.immutable {
+		c.redactedKeys = maps.Clone(c.redactedKeys)
 	}
-	out := make(map[string]any, len(input))
-	for k, v := range input {
-		if _, ok := m.redactedKeys[k]; ok {
-			out[k] = "[REDACTED]"
-		} else {
-			out[k] = v
-		}
+	if c.redactedKeys == nil {
+		c.redactedKeys = make(map[string]struct{})
 	}
-	return out
+	c.redactedKeys[key] = struct{}{}
+	return c
 }
 
 func (m *morgana) ToJsonSafe() string {
@@ -590,8 +723,10 @@ func (m *morgana) ToJsonSafe() string {
 		StackFrames []StackFrame   `json:"stackFrames,omitempty"`
 		MetaData    map[string]any `json:"metaData,omitempty"`
 		FieldErrors []FieldError   `json:"fieldErrors,omitempty"`
+		Annotations []Annotation   `json:"annotations,omitempty"`
 		ID          string         `json:"id,omitempty"`
 	}
+	m = m.safeView(false)
 	s := safe{
 		Type:        m.Type,
 		With:        m.WithValue,
@@ -602,6 +737,7 @@ func (m *morgana) ToJsonSafe() string {
 		StackFrames: m.StackFrames,
 		MetaData:    m.redactMap(m.MetaData),
 		FieldErrors: m.FieldErrors,
+		Annotations: m.redactAnnotations(),
 		ID:          m.ID,
 	}
 	b, err := json.Marshal(s)
@@ -620,16 +756,17 @@ func (m *morgana) WriteHTTP(w http.ResponseWriter, safe bool) {
 	if w == nil {
 		return
 	}
-	if m.StatusCode == 0 {
-		m.StatusCode = http.StatusInternalServerError
+	statusCode := m.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(m.StatusCode)
+	w.WriteHeader(statusCode)
 	if safe {
-		_, _ = w.Write([]byte(m.ToJsonSafe()))
+		_, _ = w.Write([]byte(signForHTTP(m.safeView(true).ToJsonSafe())))
 		return
 	}
-	_, _ = w.Write([]byte(m.ToJson()))
+	_, _ = w.Write([]byte(signForHTTP(m.ToJson())))
 }
 
 func (m *morgana) ToFields() map[string]any {
@@ -650,19 +787,41 @@ func (m *morgana) ToFields() map[string]any {
 	if len(m.FieldErrors) != 0 {
 		fields["fieldErrors"] = m.FieldErrors
 	}
+	if len(m.Annotations) != 0 {
+		fields["annotations"] = m.redactAnnotations()
+	}
 	if len(m.MetaData) != 0 {
 		fields["metaData"] = m.redactMap(m.MetaData)
 	}
 	return fields
 }
 
+// WithTrace copies correlation values from ctx into the metadata: the typed
+// keys set with ContextWithRequestID, ContextWithTraceID and friends, the
+// legacy string keys such as "trace_id", and the values returned by
+// extractors registered with RegisterExtractor, whose redaction hints are
+// applied with WithRedactedKey.
 func (m *morgana) WithTrace(ctx context.Context) Morgana {
 	if ctx == nil {
 		return m
 	}
+	md, redact := traceValues(ctx)
+	if len(md) == 0 {
+		return m
+	}
+	out := m.WithAddMetaData(md)
+	for _, k := range redact {
+		out = out.WithRedactedKey(k)
+	}
+	return out
+}
+
+// traceValues collects the metadata WithTrace adds and the keys to redact.
+func traceValues(ctx context.Context) (map[string]any, []string) {
+	md := make(map[string]any)
 	try := func(key any) {
 		if v := ctx.Value(key); v != nil {
-			m.WithAddMetaDataKey(fmt.Sprintf("%v", key), v)
+			md[fmt.Sprintf("%v", key)] = v
 		}
 	}
 	// common keys
@@ -672,12 +831,17 @@ func (m *morgana) WithTrace(ctx context.Context) Morgana {
 	try("requestId")
 	try("correlation_id")
 	try("correlationId")
-	return m
+	extracted, redact := traceMetaData(ctx)
+	for k, v := range extracted {
+		md[k] = v
+	}
+	return md, redact
 }
 
 func (m *morgana) WithID(id string) Morgana {
-	m.ID = id
-	return m
+	c := m.edit()
+	c.ID = id
+	return c
 }
 
 func (m *morgana) GetID() string {
@@ -688,20 +852,34 @@ func (m *morgana) ensureID() {
 	if m.ID != "" {
 		return
 	}
+	m.ID = newID()
+}
+
+// newID returns 16 random bytes in hex, or "" if randomness is unavailable.
+func newID() string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
 	if err != nil {
-		return
+		return ""
 	}
-	m.ID = hex.EncodeToString(b)
+	return hex.EncodeToString(b)
 }
 
 func (m *morgana) WithFieldError(field string, code string, msg string) Morgana {
-	m.FieldErrors = append(m.FieldErrors, FieldError{Field: field, Code: code, Msg: msg})
-	return m
+	c := m.edit()
+	if m.immutable {
+		c.FieldErrors = slices.Clip(c.FieldErrors)
+	}
+	c.FieldErrors = append(c.FieldErrors, FieldError{Field: field, Code: code, Msg: msg})
+	return c
 }
 
+// GetFieldErrors returns the field errors. For an immutable Morgana the
+// slice is a copy.
 func (m *morgana) GetFieldErrors() []FieldError {
+	if m.immutable {
+		return slices.Clone(m.FieldErrors)
+	}
 	return m.FieldErrors
 }
 
@@ -764,8 +942,9 @@ func (m *morgana) FromGRPCCode(code int) Morgana {
 	default:
 		httpCode = http.StatusInternalServerError
 	}
-	m.StatusCode = httpCode
-	return m
+	c := m.edit()
+	c.StatusCode = httpCode
+	return c
 }
 
 // Implement fmt.Formatter for pretty printing with %+v
@@ -805,44 +984,3 @@ func Recover(fn func()) (err error) {
 	}
 	return nil
 }
-
-// Join joins multiple errors, preferring Morgana if present
-func Join(errs ...error) error {
-	var firstM Morgana
-	for _, e := range errs {
-		if e == nil {
-			continue
-		}
-		if m := GetMorgana(e); m != nil {
-			if firstM == nil {
-				firstM = m.Clone(4)
-			}
-			firstM.WithError(e)
-		}
-	}
-	if firstM != nil {
-		return firstM.ToError()
-	}
-	// Fallback to local join
-	return joinErrors(errs...)
-}
-
-func joinErrors(errs ...error) error {
-	var nonNil []error
-	for _, e := range errs {
-		if e != nil {
-			nonNil = append(nonNil, e)
-		}
-	}
-	if len(nonNil) == 0 {
-		return nil
-	}
-	if len(nonNil) == 1 {
-		return nonNil[0]
-	}
-	root := nonNil[0]
-	for i := 1; i < len(nonNil); i++ {
-		root = perror.Wrap(nonNil[i], root.Error())
-	}
-	return root
-}
@@ -2,13 +2,20 @@ package morgana_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/bi0dread/morgana"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMorgana(t *testing.T) {
@@ -159,6 +166,70 @@ func TestWrap(t *testing.T) {
 		wrappedErr := morgana.Wrap(err1, nil)
 		assert.Equal(t, err1, wrappedErr)
 	})
+
+	t.Run("Wrap plain errors", func(t *testing.T) {
+		wrappedErr := morgana.Wrap(err1, err2)
+		assert.Equal(t, "Error 2: Error 1", wrappedErr.Error())
+		assert.ErrorIs(t, wrappedErr, err1)
+		assert.ErrorIs(t, wrappedErr, err2)
+	})
+
+	inner := morgana.New("DB").WithCustomCode("TIMEOUT").WithStatusCode(http.StatusGatewayTimeout).
+		WithAddMetaDataKey("query", "select").WithAddMetaDataKey("password", "secret").WithRedactedKey("password").WithCause(err1)
+	outer := morgana.New("API").WithCustomCode("FAILED").WithStatusCode(http.StatusBadRequest).
+		WithAddMetaDataKey("query", "outer")
+
+	t.Run("Does not mutate", func(t *testing.T) {
+		before, beforeOuter := inner.ToJson(), outer.ToJson()
+		_ = morgana.Wrap(inner.ToError(), outer.ToError())
+		_ = morgana.WrapWith(morgana.WrapOuterWins, inner.ToError(), outer.ToError())
+		_ = morgana.Wrap(inner.ToError(), err2)
+		assert.Equal(t, before, inner.ToJson())
+		assert.Equal(t, beforeOuter, outer.ToJson())
+		assert.Empty(t, inner.GetMorganaStackErrors())
+	})
+
+	t.Run("Strategies", func(t *testing.T) {
+		innerWins := morgana.GetMorgana(morgana.Wrap(inner.ToError(), outer.ToError()))
+		assert.Equal(t, "TIMEOUT", innerWins.GetCustomCode())
+		assert.Equal(t, "select", innerWins.GetMetaDataKey("query"))
+
+		outerWins := morgana.GetMorgana(morgana.WrapWith(morgana.WrapOuterWins, inner.ToError(), outer.ToError()))
+		assert.Equal(t, "FAILED", outerWins.GetCustomCode())
+		assert.Equal(t, http.StatusBadRequest, outerWins.GetStatusCode())
+		assert.Equal(t, "outer", outerWins.GetMetaDataKey("query"))
+		assert.Contains(t, outerWins.ToJsonSafe(), `"password":"[REDACTED]"`)
+
+		mostSevere := morgana.GetMorgana(morgana.WrapWith(morgana.WrapMostSevere, inner.ToError(), outer.ToError()))
+		assert.Equal(t, "TIMEOUT", mostSevere.GetCustomCode())
+	})
+
+	t.Run("Chains preserved", func(t *testing.T) {
+		wrappedErr := morgana.WrapWith(morgana.WrapOuterWins, inner.ToError(), outer.ToError())
+		assert.ErrorIs(t, wrappedErr, inner)
+		assert.ErrorIs(t, wrappedErr, outer)
+		assert.ErrorIs(t, wrappedErr, err1)
+
+		wrappedErr = morgana.Wrap(err2, outer.ToError())
+		assert.Equal(t, "FAILED", morgana.GetMorgana(wrappedErr).GetCustomCode())
+		assert.ErrorIs(t, wrappedErr, err2)
+	})
+
+	t.Run("Sentinels on both sides", func(t *testing.T) {
+		innerSentinel := errors.New("inner sentinel")
+		outerSentinel := errors.New("outer sentinel")
+		innerErr := fmt.Errorf("repo: %w: %w", innerSentinel, inner.ToError())
+		outerErr := fmt.Errorf("api: %w: %w", outerSentinel, outer.ToError())
+
+		for _, strategy := range []morgana.WrapStrategy{morgana.WrapInnerWins, morgana.WrapOuterWins} {
+			wrappedErr := morgana.WrapWith(strategy, innerErr, outerErr)
+			assert.ErrorIs(t, wrappedErr, innerSentinel)
+			assert.ErrorIs(t, wrappedErr, outerSentinel)
+			assert.ErrorIs(t, wrappedErr, err1)
+		}
+		assert.Equal(t, "FAILED", morgana.GetMorgana(morgana.WrapWith(morgana.WrapOuterWins, innerErr, outerErr)).GetCustomCode())
+		assert.Equal(t, "TIMEOUT", morgana.GetMorgana(morgana.Wrap(innerErr, outerErr)).GetCustomCode())
+	})
 }
 
 func TestGetStringDetail(t *testing.T) {
@@ -166,3 +237,519 @@ func TestGetStringDetail(t *testing.T) {
 	detail := morgana.GetStringDetail(err)
 	assert.Equal(t, "Test error", detail)
 }
+
+func TestImmutable(t *testing.T) {
+	template := morgana.New("Template").WithCustomCode("TPL").WithAddMetaDataKey("nested", map[string]any{"k": "v"}).Immutable()
+
+	t.Run("WithReturnsNewValue", func(t *testing.T) {
+		derived := template.WithAddMetaDataKey("request", "r1").WithStatusCode(http.StatusBadRequest)
+		assert.True(t, derived.IsImmutable())
+		assert.Equal(t, "r1", derived.GetMetaDataKey("request"))
+		assert.Equal(t, http.StatusBadRequest, derived.GetStatusCode())
+		assert.False(t, template.HasMetaDataKey("request"))
+		assert.Equal(t, 0, template.GetStatusCode())
+	})
+
+	t.Run("ConcurrentDerivation", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				d := template.WithAddMetaDataKey("i", i).WithFieldError("f", "c", "m").WithError(errors.New("x"))
+				assert.Equal(t, i, d.GetMetaDataKey("i"))
+				assert.Len(t, d.GetFieldErrors(), 1)
+			}(i)
+		}
+		wg.Wait()
+		assert.Empty(t, template.GetFieldErrors())
+		assert.Empty(t, template.GetMorganaStackErrors())
+	})
+
+	t.Run("DeepClone", func(t *testing.T) {
+		orig := morgana.New("Orig").WithAddMetaDataKey("nested", map[string]any{"k": "v"}).
+			WithError(morgana.New("Child").ToError()).WithRedactedKey("nested")
+		clone := orig.DeepClone()
+		assert.False(t, clone.IsImmutable())
+		assert.Equal(t, orig.GetID(), clone.GetID())
+
+		clone.GetMetaData()["nested"].(map[string]any)["k"] = "changed"
+		assert.Equal(t, "v", orig.GetMetaData()["nested"].(map[string]any)["k"])
+
+		clone.GetMorganaStackErrors()[0].WithMessage("changed")
+		assert.Empty(t, orig.GetMorganaStackErrors()[0].GetMessage())
+		assert.Contains(t, clone.ToJsonSafe(), "[REDACTED]")
+	})
+
+	t.Run("GettersDoNotLeak", func(t *testing.T) {
+		tmpl := morgana.New("Tmpl").WithAddMetaDataKey("nested", map[string]any{"k": "v"}).
+			WithError(morgana.New("Child").ToError()).Immutable()
+
+		tmpl.GetMetaData()["leak"] = 1
+		tmpl.GetMetaData()["nested"].(map[string]any)["k"] = "changed"
+		assert.False(t, tmpl.HasMetaDataKey("leak"))
+		assert.Equal(t, "v", tmpl.GetMetaData()["nested"].(map[string]any)["k"])
+
+		child := tmpl.GetMorganaStackErrors()[0]
+		assert.True(t, child.IsImmutable())
+		child.WithAddMetaDataKey("req", "r1")
+		tmpl.GetMorganaStackErrors()[0] = morgana.New("Other")
+
+		derived := tmpl.WithAddMetaDataKey("req", "r2")
+		assert.Equal(t, "Child", derived.GetMorganaStackErrors()[0].GetType())
+		assert.False(t, derived.GetMorganaStackErrors()[0].HasMetaDataKey("req"))
+		assert.False(t, tmpl.GetMorganaStackErrors()[0].HasMetaDataKey("req"))
+	})
+	leaky := morgana.New("Tmpl").WithAddMetaDataKey("n", map[string]any{"k": "v"}).
+		WithFieldError("f", "c", "m").WithFullStack(1, 4).
+		WithAnnotation(1, "a", map[string]any{"k": "v"}).Immutable()
+
+	t.Run("GetMetaDataKeyDoesNotLeak", func(t *testing.T) {
+		leaky.GetMetaDataKey("n").(map[string]any)["k"] = "x"
+		assert.Equal(t, "v", leaky.GetMetaDataKey("n").(map[string]any)["k"])
+	})
+
+	t.Run("GetFieldErrorsDoesNotLeak", func(t *testing.T) {
+		leaky.GetFieldErrors()[0].Msg = "x"
+		assert.Equal(t, "m", leaky.GetFieldErrors()[0].Msg)
+	})
+
+	t.Run("GetStackFramesDoesNotLeak", func(t *testing.T) {
+		require.NotEmpty(t, leaky.GetStackFrames())
+		file := leaky.GetStackFrames()[0].File
+		leaky.GetStackFrames()[0].File = "x"
+		assert.Equal(t, file, leaky.GetStackFrames()[0].File)
+	})
+
+	t.Run("GetAnnotationsDoesNotLeak", func(t *testing.T) {
+		leaky.GetAnnotations()[0].Msg = "x"
+		leaky.GetAnnotations()[0].MetaData["k"] = "x"
+		assert.Equal(t, "a", leaky.GetAnnotations()[0].Msg)
+		assert.Equal(t, "v", leaky.GetAnnotations()[0].MetaData["k"])
+	})
+}
+
+func TestMorganaAsError(t *testing.T) {
+	m := morgana.New("Err").WithCustomCode("E1").WithMessage("failed")
+
+	t.Run("ImplementsError", func(t *testing.T) {
+		var err error = m
+		assert.Equal(t, m.ToError().Error(), err.Error())
+		assert.Contains(t, err.Error(), "failed")
+	})
+
+	t.Run("ErrorsAsThroughWrappers", func(t *testing.T) {
+		wrapped := fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", m.ToError()))
+		var found morgana.Morgana
+		assert.True(t, errors.As(wrapped, &found))
+		assert.Equal(t, "E1", found.GetCustomCode())
+
+		direct := fmt.Errorf("outer: %w", m)
+		assert.True(t, errors.As(direct, &found))
+		assert.Equal(t, m.GetID(), found.GetID())
+	})
+
+	t.Run("GetMorganaTraversesChain", func(t *testing.T) {
+		wrapped := fmt.Errorf("ctx: %w", m.ToError())
+		assert.Equal(t, m.GetID(), morgana.GetMorgana(wrapped).GetID())
+		assert.Equal(t, m.GetID(), morgana.FromError(wrapped).GetID())
+		assert.Nil(t, morgana.GetMorgana(errors.New("plain")))
+	})
+
+	t.Run("UnwrapCause", func(t *testing.T) {
+		cause := errors.New("root")
+		assert.ErrorIs(t, morgana.New("C").WithCause(cause), cause)
+	})
+}
+
+func TestIs(t *testing.T) {
+	template := morgana.New("USER").WithCustomCode("NOT_FOUND").Immutable()
+	sentinel := template.ToError()
+
+	t.Run("ClonesMatchTemplate", func(t *testing.T) {
+		err := fmt.Errorf("lookup: %w", template.Clone(1).WithAddMetaDataKey("id", 1).ToError())
+		assert.ErrorIs(t, err, sentinel)
+		assert.ErrorIs(t, err, template)
+	})
+
+	t.Run("DifferentIdentity", func(t *testing.T) {
+		err := morgana.New("USER").WithCustomCode("CONFLICT").ToError()
+		assert.NotErrorIs(t, err, sentinel)
+	})
+
+	t.Run("ForeignErrorsNeverMatch", func(t *testing.T) {
+		general := morgana.New("GENERAL").ToError()
+		assert.NotErrorIs(t, general, errors.New("foreign"))
+	})
+
+	t.Run("PlainSentinelCause", func(t *testing.T) {
+		err := morgana.New("IO").WithCause(io.EOF).ToError()
+		assert.ErrorIs(t, err, io.EOF)
+		assert.NotErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("MatchModes", func(t *testing.T) {
+		inst := morgana.New("OTHER").WithCustomCode("NOT_FOUND").With("x").ToError()
+		assert.NotErrorIs(t, inst, sentinel)
+		assert.ErrorIs(t, inst, template.WithMatchMode(morgana.MatchCode))
+		assert.NotErrorIs(t, inst, template.WithMatchMode(morgana.MatchType))
+		assert.ErrorIs(t, morgana.New("USER").ToError(), template.WithMatchMode(morgana.MatchType))
+
+		morgana.SetDefaultMatchMode(morgana.MatchCode)
+		defer morgana.SetDefaultMatchMode(morgana.MatchDefault)
+		assert.ErrorIs(t, inst, sentinel)
+	})
+}
+
+func TestDefine(t *testing.T) {
+	errUserNotFound := morgana.Define("USER", "USER_NOT_FOUND", http.StatusNotFound, "user {id} not found")
+
+	t.Run("Instance", func(t *testing.T) {
+		m := errUserNotFound.New(map[string]any{"id": 42}).WithAddMetaDataKey("tenant", "t1")
+		assert.Equal(t, "user 42 not found", m.GetMessage())
+		assert.Equal(t, http.StatusNotFound, m.GetStatusCode())
+		assert.Equal(t, "USER_NOT_FOUND", m.GetCustomCode())
+		assert.NotEmpty(t, m.GetID())
+		assert.Contains(t, m.String(), "morgana_test.go")
+		assert.False(t, m.IsImmutable())
+	})
+
+	t.Run("ErrorsIs", func(t *testing.T) {
+		err := fmt.Errorf("handler: %w", errUserNotFound.New(nil).ToError())
+		assert.ErrorIs(t, err, errUserNotFound)
+		assert.NotErrorIs(t, err, morgana.Define("USER", "USER_CONFLICT", http.StatusConflict, "conflict"))
+
+		withValue := fmt.Errorf("handler: %w", errUserNotFound.New(nil).With("lookup").WithMatchMode(morgana.MatchExact).ToError())
+		assert.ErrorIs(t, withValue, errUserNotFound)
+		assert.NotErrorIs(t, withValue, morgana.Define("ORDER", "USER_NOT_FOUND", http.StatusNotFound, "other kind"))
+	})
+
+	t.Run("DefinitionAsError", func(t *testing.T) {
+		m := morgana.GetMorgana(errUserNotFound)
+		require.NotNil(t, m)
+		assert.Equal(t, "USER_NOT_FOUND", m.GetCustomCode())
+		assert.True(t, m.IsImmutable())
+
+		m = morgana.FromError(fmt.Errorf("wrapped: %w", errUserNotFound))
+		assert.Equal(t, "USER", m.GetType())
+		assert.Equal(t, http.StatusNotFound, m.GetStatusCode())
+	})
+
+	t.Run("DefinitionUnchanged", func(t *testing.T) {
+		errUserNotFound.New(map[string]any{"id": 1}).WithStatusCode(http.StatusTeapot)
+		assert.Equal(t, http.StatusNotFound, errUserNotFound.StatusCode())
+		assert.Equal(t, "user {id} not found", errUserNotFound.Message())
+	})
+
+	t.Run("Placeholders", func(t *testing.T) {
+		assert.Equal(t, []string{"id", "name"}, morgana.Placeholders("{id} {name} {id}"))
+	})
+}
+
+func TestWithTraceTypedKeys(t *testing.T) {
+	ctx := morgana.ContextWithRequestID(context.Background(), "req-1")
+	ctx = morgana.ContextWithTraceID(ctx, "trace-1")
+	ctx = morgana.ContextWithCorrelationID(ctx, "corr-1")
+
+	m := morgana.New("Trace").WithTrace(ctx)
+	assert.Equal(t, "req-1", m.GetMetaDataKey(morgana.MetaDataRequestID))
+	assert.Equal(t, "trace-1", m.GetMetaDataKey(morgana.MetaDataTraceID))
+	assert.Equal(t, "corr-1", m.GetMetaDataKey(morgana.MetaDataCorrelationID))
+	assert.False(t, m.HasMetaDataKey(morgana.MetaDataSpanID))
+
+	id, ok := morgana.RequestIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "req-1", id)
+
+	morgana.RegisterContextExtractor(func(ctx context.Context) map[string]any {
+		if id, ok := morgana.TraceIDFromContext(ctx); ok {
+			return map[string]any{"trace_url": "https://tracing.example.com/" + id}
+		}
+		return nil
+	})
+	m = morgana.New("Trace").WithTrace(ctx)
+	assert.Equal(t, "https://tracing.example.com/trace-1", m.GetMetaDataKey("trace_url"))
+}
+
+func TestExtractors(t *testing.T) {
+	type tenantKey struct{}
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	require.NoError(t, morgana.RegisterExtractor(morgana.Extractor{
+		Name:     "tenant-high",
+		Priority: 10,
+		Extract: func(ctx context.Context) map[string]any {
+			return map[string]any{"tenant": ctx.Value(tenantKey{}), "user_email": "a@example.com"}
+		},
+		RedactKeys: []string{"user_email"},
+	}))
+	defer morgana.UnregisterExtractor("tenant-high")
+	require.NoError(t, morgana.RegisterExtractor(morgana.Extractor{
+		Name:    "tenant-low",
+		Extract: func(ctx context.Context) map[string]any { return map[string]any{"tenant": "fallback", "flag": true} },
+	}))
+	defer morgana.UnregisterExtractor("tenant-low")
+
+	assert.Error(t, morgana.RegisterExtractor(morgana.Extractor{Name: "tenant-low", Extract: func(context.Context) map[string]any { return nil }}))
+	assert.Error(t, morgana.RegisterExtractor(morgana.Extractor{Name: "no-func"}))
+
+	m := morgana.New("Extract").WithTrace(ctx)
+	assert.Equal(t, "acme", m.GetMetaDataKey("tenant"))
+	assert.Equal(t, true, m.GetMetaDataKey("flag"))
+	assert.Equal(t, "a@example.com", m.GetMetaDataKey("user_email"))
+	assert.Contains(t, m.ToJsonSafe(), `"user_email":"[REDACTED]"`)
+
+	assert.True(t, morgana.UnregisterExtractor("tenant-low"))
+	assert.False(t, morgana.UnregisterExtractor("tenant-low"))
+	assert.False(t, morgana.New("Extract").WithTrace(ctx).HasMetaDataKey("flag"))
+}
+
+func TestContextFields(t *testing.T) {
+	ctx := morgana.ContextWithFields(context.Background(), map[string]any{"tenant": "acme", "job": "outer"})
+	ctx = morgana.ContextWithRequestID(ctx, "req-9")
+	inner := morgana.ContextWithFields(ctx, map[string]any{"job": "import", "batch": 3})
+
+	assert.Equal(t, map[string]any{"tenant": "acme", "job": "import", "batch": 3}, morgana.FieldsFromContext(inner))
+	assert.Equal(t, map[string]any{"tenant": "acme", "job": "outer"}, morgana.FieldsFromContext(ctx))
+	assert.Nil(t, morgana.FieldsFromContext(context.Background()))
+
+	m := morgana.NewCtx(inner, "Repo")
+	assert.Equal(t, "acme", m.GetMetaDataKey("tenant"))
+	assert.Equal(t, "import", m.GetMetaDataKey("job"))
+	assert.Equal(t, "req-9", m.GetMetaDataKey(morgana.MetaDataRequestID))
+
+	m = morgana.New("Repo").WithAddMetaDataKey("explicit", 1).WithContext(ctx)
+	assert.Equal(t, 1, m.GetMetaDataKey("explicit"))
+	assert.Equal(t, "outer", m.GetMetaDataKey("job"))
+
+	m = morgana.New("Repo").WithAddMetaDataKey("job", "explicit").
+		WithAddMetaDataKey(morgana.MetaDataRequestID, "req-explicit").WithContext(inner)
+	assert.Equal(t, "explicit", m.GetMetaDataKey("job"))
+	assert.Equal(t, "req-explicit", m.GetMetaDataKey(morgana.MetaDataRequestID))
+	assert.Equal(t, "acme", m.GetMetaDataKey("tenant"))
+}
+
+func TestJoin(t *testing.T) {
+	notFound := morgana.New("NotFound").WithCustomCode("NF").WithStatusCode(http.StatusNotFound)
+	conflict := morgana.New("Conflict").WithCustomCode("CONFLICT").WithStatusCode(http.StatusConflict)
+	unavailable := morgana.New("Down").WithCustomCode("DOWN").WithStatusCode(http.StatusServiceUnavailable)
+	plain := errors.New("plain")
+
+	t.Run("MembersReachable", func(t *testing.T) {
+		err := morgana.Join(notFound.ToError(), nil, plain, conflict)
+		assert.ErrorIs(t, err, notFound)
+		assert.ErrorIs(t, err, conflict)
+		assert.ErrorIs(t, err, plain)
+
+		var multi *morgana.MultiError
+		require.True(t, errors.As(err, &multi))
+		assert.Len(t, multi.Errors(), 3)
+	})
+
+	t.Run("SingleAndEmpty", func(t *testing.T) {
+		assert.Nil(t, morgana.Join(nil, nil))
+		assert.Equal(t, plain, morgana.Join(nil, plain))
+	})
+
+	t.Run("StatusPolicies", func(t *testing.T) {
+		mixed := morgana.Join(notFound.ToError(), unavailable.ToError()).(*morgana.MultiError)
+		assert.Equal(t, http.StatusServiceUnavailable, mixed.StatusCode())
+
+		byClass := morgana.JoinWithPolicy(morgana.StatusByClass, notFound.ToError(), unavailable.ToError()).(*morgana.MultiError)
+		assert.Equal(t, http.StatusInternalServerError, byClass.StatusCode())
+		byClass = morgana.JoinWithPolicy(morgana.StatusByClass, notFound.ToError(), conflict.ToError()).(*morgana.MultiError)
+		assert.Equal(t, http.StatusBadRequest, byClass.StatusCode())
+
+		first := morgana.JoinWithPolicy(morgana.StatusFirst, conflict.ToError(), unavailable.ToError()).(*morgana.MultiError)
+		assert.Equal(t, http.StatusConflict, first.StatusCode())
+	})
+
+	t.Run("JSONAndFromError", func(t *testing.T) {
+		err := morgana.Join(notFound.ToError(), plain)
+		var doc struct {
+			StatusCode int              `json:"statusCode"`
+			Errors     []map[string]any `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(err.(*morgana.MultiError).ToJsonSafe()), &doc))
+		assert.Equal(t, http.StatusInternalServerError, doc.StatusCode)
+		require.Len(t, doc.Errors, 2)
+		assert.Equal(t, "NF", doc.Errors[0]["customCode"])
+		assert.Equal(t, "plain", doc.Errors[1]["msg"])
+
+		m := morgana.FromError(err)
+		assert.Equal(t, "MULTI", m.GetType())
+		assert.Equal(t, http.StatusInternalServerError, m.GetStatusCode())
+		assert.Len(t, m.GetMorganaStackErrors(), 2)
+	})
+
+	t.Run("WrappedJoin", func(t *testing.T) {
+		err := fmt.Errorf("batch: %w", morgana.Join(notFound.ToError(), unavailable.ToError()))
+
+		m := morgana.FromError(err)
+		assert.Equal(t, "MULTI", m.GetType())
+		assert.Equal(t, http.StatusServiceUnavailable, m.GetStatusCode())
+		assert.Len(t, m.GetMorganaStackErrors(), 2)
+
+		got := morgana.GetMorgana(err)
+		require.NotNil(t, got)
+		assert.Equal(t, "MULTI", got.GetType())
+		assert.Equal(t, "MULTI", morgana.GetMorgana(morgana.Join(notFound.ToError(), conflict)).GetType())
+		assert.Contains(t, morgana.GetStringDetail(err), "MULTI")
+	})
+
+	t.Run("StableAggregate", func(t *testing.T) {
+		err := fmt.Errorf("batch: %w", morgana.Join(notFound.ToError(), conflict))
+		first := morgana.GetMorgana(err)
+		assert.Equal(t, first.GetID(), morgana.GetMorgana(err).GetID())
+		assert.Equal(t, first.GetID(), morgana.FromError(err).GetID())
+		assert.True(t, first.IsImmutable())
+
+		var multi *morgana.MultiError
+		require.True(t, errors.As(err, &multi))
+		assert.Equal(t, first.GetID(), multi.ToMorgana().GetID())
+		assert.Equal(t, "NF", morgana.GetMorgana(multi.Errors()[0]).GetCustomCode())
+	})
+
+	t.Run("WithErrorUnderstandsJoin", func(t *testing.T) {
+		m := morgana.New("Batch").WithError(errors.Join(notFound.ToError(), conflict, plain))
+		stack := m.GetMorganaStackErrors()
+		require.Len(t, stack, 3)
+		assert.Equal(t, "NF", stack[0].GetCustomCode())
+		assert.Equal(t, "CONFLICT", stack[1].GetCustomCode())
+		assert.Equal(t, "plain", stack[2].GetMessage())
+	})
+}
+
+func TestUnwrapStackErrors(t *testing.T) {
+	notFound := morgana.New("USER").WithCustomCode("NOT_FOUND").Immutable()
+	io := errors.New("disk")
+
+	t.Run("StackErrorsReachable", func(t *testing.T) {
+		inner := notFound.Clone(1).WithCause(io)
+		err := morgana.New("API").WithCustomCode("FAILED").WithError(inner).ToError()
+
+		assert.ErrorIs(t, err, notFound)
+		assert.ErrorIs(t, err, io)
+
+		var m morgana.Morgana
+		require.True(t, errors.As(err, &m))
+		assert.Equal(t, "FAILED", m.GetCustomCode())
+
+		unwrapped := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, unwrapped, 2)
+		require.True(t, errors.As(unwrapped[0], &m))
+		assert.Equal(t, "NOT_FOUND", m.GetCustomCode())
+		assert.Equal(t, io, unwrapped[1])
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		leaf := morgana.New("DB").WithCustomCode("TIMEOUT")
+		mid := morgana.New("REPO").WithError(leaf)
+		err := fmt.Errorf("handler: %w", morgana.New("API").WithError(mid).ToError())
+		assert.ErrorIs(t, err, leaf)
+		assert.NotErrorIs(t, err, notFound)
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		a := morgana.New("A").WithCustomCode("A")
+		b := morgana.New("B").WithCustomCode("B").WithError(a)
+		a.WithError(b).WithError(a)
+		assert.ErrorIs(t, a.ToError(), b)
+		assert.NotErrorIs(t, a.ToError(), notFound)
+		assert.Len(t, a.ToError().(interface{ Unwrap() []error }).Unwrap(), 1)
+	})
+	t.Run("SingleCausePath", func(t *testing.T) {
+		err := notFound.Clone(1).WithCause(io).ToError()
+		assert.Nil(t, errors.Unwrap(err))
+		assert.Equal(t, io, morgana.GetMorgana(err).Cause())
+
+		outer := morgana.New("API").WithCause(fmt.Errorf("handler: %w", err))
+		assert.Equal(t, io, outer.Cause())
+	})
+
+	t.Run("TypedLinksReachable", func(t *testing.T) {
+		pathErr := &fs.PathError{Op: "open", Path: "cfg.yaml", Err: fs.ErrNotExist}
+		for _, m := range []morgana.Morgana{
+			morgana.New("Config").WithError(fmt.Errorf("open cfg: %w", pathErr)),
+			morgana.New("Config").WithCause(pathErr),
+		} {
+			err := m.ToError()
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+			var target *fs.PathError
+			require.True(t, errors.As(err, &target))
+			assert.Equal(t, "cfg.yaml", target.Path)
+		}
+		assert.Equal(t, fs.ErrNotExist, morgana.New("Config").WithCause(pathErr).Cause())
+	})
+
+	t.Run("CauseCycle", func(t *testing.T) {
+		a := morgana.New("A")
+		b := morgana.New("B").WithCause(a.ToError())
+		a.WithCause(b.ToError())
+		assert.NotNil(t, a.Cause())
+		assert.NotErrorIs(t, a.ToError(), notFound)
+	})
+}
+
+func TestWrapf(t *testing.T) {
+	base := morgana.New("USER").WithCustomCode("NOT_FOUND").WithStatusCode(http.StatusNotFound).Immutable()
+
+	loadUser := func(id int) error {
+		return morgana.Wrapf(base.ToError(), "loading user %d", id)
+	}
+	handle := func() error {
+		return morgana.Annotate(loadUser(7), map[string]any{"route": "/users"}, "handling request")
+	}
+
+	err := handle()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, base)
+
+	m := morgana.GetMorgana(err)
+	require.NotNil(t, m)
+	assert.Equal(t, "USER", m.GetType())
+	assert.Equal(t, "NOT_FOUND", m.GetCustomCode())
+	assert.Empty(t, base.GetAnnotations())
+
+	annotations := m.GetAnnotations()
+	require.Len(t, annotations, 2)
+	assert.Equal(t, "loading user 7", annotations[0].Msg)
+	assert.Contains(t, annotations[0].Frame.Function, "TestWrapf.func1")
+	assert.Contains(t, annotations[0].Frame.File, "morgana_test.go")
+	assert.Equal(t, "handling request", annotations[1].Msg)
+	assert.Contains(t, annotations[1].Frame.Function, "TestWrapf.func2")
+	assert.Equal(t, "/users", annotations[1].MetaData["route"])
+
+	detail := fmt.Sprintf("%+v", m)
+	first := strings.Index(detail, "loading user 7")
+	second := strings.Index(detail, "handling request")
+	assert.True(t, first >= 0 && second > first, detail)
+
+	t.Run("ForeignAndNil", func(t *testing.T) {
+		assert.Nil(t, morgana.Wrapf(nil, "ignored"))
+		cause := errors.New("disk")
+		err := morgana.Wrapf(cause, "reading %s", "cfg")
+		assert.ErrorIs(t, err, cause)
+		assert.Equal(t, "reading cfg", morgana.GetMorgana(err).GetAnnotations()[0].Msg)
+	})
+
+	t.Run("KeepsWrappedChain", func(t *testing.T) {
+		sentinel := errors.New("sentinel")
+		err := morgana.Wrapf(fmt.Errorf("ctx: %w: %w", sentinel, base.ToError()), "x")
+		assert.ErrorIs(t, err, sentinel)
+		assert.ErrorIs(t, err, base)
+		assert.True(t, strings.HasPrefix(err.Error(), "x: ctx: sentinel: "), err.Error())
+		assert.Equal(t, "x", morgana.GetMorgana(err).GetAnnotations()[0].Msg)
+		assert.Equal(t, "NOT_FOUND", morgana.GetMorgana(err).GetCustomCode())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		back, err := morgana.FromJSON([]byte(m.ToJson()))
+		require.NoError(t, err)
+		assert.Equal(t, annotations[0].Msg, back.GetAnnotations()[0].Msg)
+		assert.Equal(t, annotations[0].Frame, back.GetAnnotations()[0].Frame)
+
+		redacted := m.DeepClone().WithRedactedKey("route")
+		assert.Contains(t, redacted.ToJsonSafe(), `"route":"[REDACTED]"`)
+	})
+}
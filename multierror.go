@@ -0,0 +1,198 @@
+package morgana
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// StatusPolicy computes the status code of a MultiError from the status
+// codes of its members. Members without a status count as 500.
+type StatusPolicy func(codes []int) int
+
+// StatusMostSevere returns the highest status code, so any 5xx wins over 4xx.
+func StatusMostSevere(codes []int) int {
+	if len(codes) == 0 {
+		return http.StatusInternalServerError
+	}
+	return slices.Max(codes)
+}
+
+// StatusFirst returns the status code of the first member.
+func StatusFirst(codes []int) int {
+	if len(codes) == 0 {
+		return http.StatusInternalServerError
+	}
+	return codes[0]
+}
+
+// StatusByClass returns the common status code when all members agree, 500
+// when any member is a 5xx, 400 when all are 4xx, and the most severe code
+// otherwise.
+func StatusByClass(codes []int) int {
+	if len(codes) == 0 {
+		return http.StatusInternalServerError
+	}
+	if slices.Min(codes) == slices.Max(codes) {
+		return codes[0]
+	}
+	all4xx := true
+	for _, c := range codes {
+		if c >= 500 {
+			return http.StatusInternalServerError
+		}
+		if c < 400 {
+			all4xx = false
+		}
+	}
+	if all4xx {
+		return http.StatusBadRequest
+	}
+	return slices.Max(codes)
+}
+
+// MultiError holds several errors, all reachable with errors.Is and
+// errors.As through Unwrap() []error. errors.As with a *Morgana target
+// yields the aggregate from ToMorgana rather than the first member, so
+// GetMorgana and FromError see a join as a whole even when it is wrapped;
+// use Errors to reach the members' Morganas.
+type MultiError struct {
+	errs      []error
+	policy    StatusPolicy
+	aggregate Morgana
+}
+
+// Join returns nil when every err is nil, the error itself when only one is
+// non-nil, and otherwise a *MultiError using StatusMostSevere.
+func Join(errs ...error) error {
+	return JoinWithPolicy(StatusMostSevere, errs...)
+}
+
+// JoinWithPolicy is Join with a custom aggregate status policy.
+func JoinWithPolicy(policy StatusPolicy, errs ...error) error {
+	var nonNil []error
+	for _, e := range errs {
+		if e != nil {
+			nonNil = append(nonNil, e)
+		}
+	}
+	switch len(nonNil) {
+	case 0:
+		return nil
+	case 1:
+		return nonNil[0]
+	}
+	if policy == nil {
+		policy = StatusMostSevere
+	}
+	e := &MultiError{errs: nonNil, policy: policy}
+	e.aggregate = e.buildMorgana()
+	return e
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.errs
+}
+
+// As sets a *Morgana target to ToMorgana.
+func (e *MultiError) As(target any) bool {
+	if t, ok := target.(*Morgana); ok {
+		*t = e.ToMorgana()
+		return true
+	}
+	return false
+}
+
+// Errors returns the members.
+func (e *MultiError) Errors() []error {
+	return slices.Clone(e.errs)
+}
+
+// StatusCode aggregates the members' status codes with the policy.
+func (e *MultiError) StatusCode() int {
+	codes := make([]int, len(e.errs))
+	for i, err := range e.errs {
+		codes[i] = http.StatusInternalServerError
+		if m := GetMorgana(err); m != nil && m.GetStatusCode() != 0 {
+			codes[i] = m.GetStatusCode()
+		}
+	}
+	return e.policy(codes)
+}
+
+// ToMorgana returns a MULTI Morgana with the aggregate status code and every
+// member attached as a stack error. It is built once by Join, so every call,
+// and every GetMorgana or FromError on the join, returns the same immutable
+// Morgana with the same ID.
+func (e *MultiError) ToMorgana() Morgana {
+	if e.aggregate == nil {
+		return e.buildMorgana()
+	}
+	return e.aggregate
+}
+
+func (e *MultiError) buildMorgana() Morgana {
+	m := New("MULTI").WithStatusCode(e.StatusCode()).WithMessage(fmt.Sprintf("%d errors occurred", len(e.errs)))
+	for _, err := range e.errs {
+		m = m.WithError(err)
+	}
+	return m.Immutable()
+}
+
+type multiErrorJSON struct {
+	StatusCode int               `json:"statusCode"`
+	Errors     []json.RawMessage `json:"errors"`
+}
+
+// MarshalJSON renders the aggregate status code and every member in the
+// ToJson schema; members without a Morgana are converted with FromError.
+func (e *MultiError) MarshalJSON() ([]byte, error) {
+	return e.marshal(false)
+}
+
+func (e *MultiError) ToJson() string {
+	b, err := e.marshal(false)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// ToJsonSafe is ToJson with every member rendered by ToJsonSafe.
+func (e *MultiError) ToJsonSafe() string {
+	b, err := e.marshal(true)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (e *MultiError) marshal(safe bool) ([]byte, error) {
+	doc := multiErrorJSON{StatusCode: e.StatusCode(), Errors: make([]json.RawMessage, 0, len(e.errs))}
+	for _, err := range e.errs {
+		m := GetMorgana(err)
+		if m == nil {
+			m = FromError(err)
+		}
+		if safe {
+			doc.Errors = append(doc.Errors, json.RawMessage(m.ToJsonSafe()))
+			continue
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		doc.Errors = append(doc.Errors, b)
+	}
+	return json.Marshal(doc)
+}
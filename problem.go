@@ -0,0 +1,223 @@
+package morgana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// ProblemJSONContentType is the media type of RFC 9457 problem details.
+const ProblemJSONContentType = "application/problem+json"
+
+// Extension members written by ToProblemDetails.
+const (
+	ProblemExtErrorType   = "errorType"
+	ProblemExtCustomCode  = "customCode"
+	ProblemExtFieldErrors = "fieldErrors"
+	ProblemExtMetaData    = "metaData"
+)
+
+// ProblemDetails is an RFC 9457 problem details object. Extensions holds
+// every member besides the five standard ones.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+var problemTypeBaseURI atomic.Value
+
+// SetProblemTypeBaseURI sets the prefix of the "type" URI written for a
+// Morgana whose CustomCode has no DocsURL in DefaultCatalog; the Morgana Type
+// is appended to it. Without a base URI such problems use "about:blank".
+func SetProblemTypeBaseURI(uri string) {
+	problemTypeBaseURI.Store(uri)
+}
+
+func getProblemTypeBaseURI() string {
+	uri, _ := problemTypeBaseURI.Load().(string)
+	return uri
+}
+
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		out[k] = v
+	}
+	typ := p.Type
+	if typ == "" {
+		typ = "about:blank"
+	}
+	out["type"] = typ
+	if p.Title != "" {
+		out["title"] = p.Title
+	}
+	if p.Status != 0 {
+		out["status"] = p.Status
+	}
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		out["instance"] = p.Instance
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON ignores standard members of the wrong JSON type, as RFC 9457
+// asks consumers to.
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = ProblemDetails{}
+	str := func(key string) string {
+		s, _ := raw[key].(string)
+		delete(raw, key)
+		return s
+	}
+	p.Type = str("type")
+	p.Title = str("title")
+	p.Detail = str("detail")
+	p.Instance = str("instance")
+	if status, ok := raw["status"].(float64); ok {
+		p.Status = int(status)
+	}
+	delete(raw, "status")
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if len(raw) != 0 {
+		p.Extensions = raw
+	}
+	return nil
+}
+
+func (m *morgana) ToProblemDetails() ProblemDetails {
+	m = m.safeView(true)
+	status := m.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	p := ProblemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   m.Msg,
+		Instance: m.ID,
+	}
+
+	docsURL, _ := m.MetaData[MetaDataDocsURL].(string)
+	if e, ok := DefaultCatalog.Lookup(m.CustomCode); ok && e.DocsURL != "" {
+		docsURL = e.DocsURL
+	}
+	switch base := getProblemTypeBaseURI(); {
+	case docsURL != "":
+		p.Type = docsURL
+	case base != "" && m.Type != "":
+		p.Type = base + url.PathEscape(m.Type)
+	}
+	if p.Type != "about:blank" && m.Type != "" {
+		p.Title = m.Type
+	}
+
+	ext := make(map[string]any)
+	if m.Type != "" {
+		ext[ProblemExtErrorType] = m.Type
+	}
+	if m.CustomCode != "" {
+		ext[ProblemExtCustomCode] = m.CustomCode
+	}
+	if len(m.FieldErrors) != 0 {
+		ext[ProblemExtFieldErrors] = m.FieldErrors
+	}
+	if md := m.publicMetaData(); len(md) != 0 {
+		ext[ProblemExtMetaData] = md
+	}
+	if len(ext) != 0 {
+		p.Extensions = ext
+	}
+	return p
+}
+
+// publicMetaData returns the metadata without the redacted keys, which are
+// redacted in nested values too.
+func (m *morgana) publicMetaData() map[string]any {
+	out := make(map[string]any, len(m.MetaData))
+	for k, v := range m.MetaData {
+		if !m.redacts(k) {
+			out[k], _ = redactor{m: m}.value(v, 0)
+		}
+	}
+	return out
+}
+
+// WriteHTTPProblem writes the Morgana as application/problem+json.
+func (m *morgana) WriteHTTPProblem(w http.ResponseWriter) {
+	if w == nil {
+		return
+	}
+	p := m.ToProblemDetails()
+	body, err := json.Marshal(p)
+	if err != nil {
+		body = []byte(`{"type":"about:blank"}`)
+	}
+	w.Header().Set("Content-Type", ProblemJSONContentType)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write([]byte(signForHTTP(string(body))))
+}
+
+// ToMorgana converts problem details, typically received from another
+// service, into a Morgana. The Type comes from the errorType extension, the
+// type URI under the base set with SetProblemTypeBaseURI, or the title.
+func (p ProblemDetails) ToMorgana() Morgana {
+	typ, _ := p.Extensions[ProblemExtErrorType].(string)
+	typeFromURI := false
+	if base := getProblemTypeBaseURI(); base != "" && strings.HasPrefix(p.Type, base) {
+		typeFromURI = true
+		if typ == "" {
+			typ, _ = url.PathUnescape(strings.TrimPrefix(p.Type, base))
+		}
+	}
+	if typ == "" {
+		typ = p.Title
+	}
+
+	m := New(typ).WithStatusCode(p.Status).WithMessage(p.Detail)
+	if p.Instance != "" {
+		m = m.WithID(p.Instance)
+	}
+	if code, ok := p.Extensions[ProblemExtCustomCode].(string); ok {
+		m = m.WithCustomCode(code)
+	}
+	if md, ok := p.Extensions[ProblemExtMetaData].(map[string]any); ok {
+		m = m.WithAddMetaData(md)
+	}
+	if raw, ok := p.Extensions[ProblemExtFieldErrors]; ok {
+		var fieldErrors []FieldError
+		if data, err := json.Marshal(raw); err == nil && json.Unmarshal(data, &fieldErrors) == nil {
+			for _, fe := range fieldErrors {
+				m = m.WithFieldError(fe.Field, fe.Code, fe.Msg)
+			}
+		}
+	}
+	if !typeFromURI && p.Type != "" && p.Type != "about:blank" && !m.HasMetaDataKey(MetaDataDocsURL) {
+		m = m.WithAddMetaDataKey(MetaDataDocsURL, p.Type)
+	}
+	return m
+}
+
+// FromProblemDetails parses an application/problem+json body into a Morgana.
+func FromProblemDetails(data []byte) (Morgana, error) {
+	var p ProblemDetails
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return p.ToMorgana(), nil
+}
@@ -0,0 +1,244 @@
+package morgana
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// RedactedValue replaces the value of a redacted metadata key under the
+// default RedactPlaceholder strategy.
+const RedactedValue = "[REDACTED]"
+
+// RedactionPolicy names metadata keys redacted from every Morgana, in addition
+// to the keys given to WithRedactedKey. Keys are matched at every level of
+// nested maps, slices and structs, and in every output: String, Error,
+// ToJson, ToJsonSafe, ToFields, problem details, the HTTP renderers and slog.
+type RedactionPolicy struct {
+	// Keys are path.Match globs such as "*password*" or "authorization",
+	// matched case-insensitively.
+	Keys []string
+	// Patterns are regular expressions matched against the key as written.
+	Patterns []*regexp.Regexp
+	// Strategies select how matching keys are shown; the first match wins.
+	Strategies []KeyStrategy
+	// Default shows the redacted keys no KeyStrategy matches. Nil writes
+	// RedactedValue.
+	Default RedactStrategy
+}
+
+// CommonSecretKeys lists key globs that usually hold credentials:
+//
+//	morgana.SetRedactionPolicy(morgana.RedactionPolicy{Keys: morgana.CommonSecretKeys})
+var CommonSecretKeys = []string{
+	"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*",
+	"*private_key*", "*credential*", "authorization", "cookie", "set-cookie",
+}
+
+var redactionPolicy atomic.Pointer[RedactionPolicy]
+
+// SetRedactionPolicy replaces the process-wide redaction policy. It returns an
+// error, leaving the current policy in place, when a glob is malformed.
+func SetRedactionPolicy(p RedactionPolicy) error {
+	compiled := RedactionPolicy{
+		Keys:       make([]string, len(p.Keys)),
+		Patterns:   append([]*regexp.Regexp(nil), p.Patterns...),
+		Strategies: make([]KeyStrategy, len(p.Strategies)),
+		Default:    p.Default,
+	}
+	for i, k := range p.Keys {
+		compiled.Keys[i] = strings.ToLower(k)
+		if _, err := path.Match(compiled.Keys[i], ""); err != nil {
+			return fmt.Errorf("morgana: redaction key %q: %w", k, err)
+		}
+	}
+	for i, ks := range p.Strategies {
+		if ks.Strategy == nil {
+			return fmt.Errorf("morgana: redaction key %q has no strategy", ks.Key)
+		}
+		compiled.Strategies[i] = KeyStrategy{Key: strings.ToLower(ks.Key), Strategy: ks.Strategy}
+		if _, err := path.Match(compiled.Strategies[i].Key, ""); err != nil {
+			return fmt.Errorf("morgana: redaction key %q: %w", ks.Key, err)
+		}
+	}
+	redactionPolicy.Store(&compiled)
+	return nil
+}
+
+// GetRedactionPolicy returns the process-wide redaction policy.
+func GetRedactionPolicy() RedactionPolicy {
+	if p := redactionPolicy.Load(); p != nil {
+		return *p
+	}
+	return RedactionPolicy{}
+}
+
+func (p *RedactionPolicy) matches(key string) bool {
+	if p == nil {
+		return false
+	}
+	lower := strings.ToLower(key)
+	for _, glob := range p.Keys {
+		if ok, _ := path.Match(glob, lower); ok {
+			return true
+		}
+	}
+	for _, re := range p.Patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return p.strategyFor(key) != nil
+}
+
+// redacts reports whether the value under key must not be shown.
+func (m *morgana) redacts(key string) bool {
+	if _, ok := m.redactedKeys[key]; ok {
+		return true
+	}
+	return redactionPolicy.Load().matches(key)
+}
+
+// redactMap returns a copy of input with the values of redacted keys replaced
+// at every level, as their RedactStrategy shows them.
+func (m *morgana) redactMap(input map[string]any) map[string]any {
+	if input == nil {
+		return nil
+	}
+	out, _ := redactor{m: m}.entries(input, 0)
+	return out
+}
+
+// maxRedactDepth bounds the walk through nested values, which may be cyclic
+// through pointers.
+const maxRedactDepth = 32
+
+// redactor walks metadata values, masking the keys m redacts and, when m is
+// a safe view, scrubbing strings with its detectors.
+type redactor struct {
+	m *morgana
+}
+
+func (r redactor) entries(input map[string]any, depth int) (map[string]any, bool) {
+	out := make(map[string]any, len(input))
+	changed := false
+	for k, v := range input {
+		if r.m.redacts(k) {
+			shown := redactedValue(k, v)
+			if r.m.public && isEncrypted(shown) {
+				shown = RedactedValue
+			}
+			out[k] = shown
+			changed = true
+			continue
+		}
+		rv, c := r.value(v, depth+1)
+		out[k] = rv
+		changed = changed || c
+	}
+	return out, changed
+}
+
+func (r redactor) slice(input []any, depth int) ([]any, bool) {
+	out := make([]any, len(input))
+	changed := false
+	for i, v := range input {
+		rv, c := r.value(v, depth+1)
+		out[i] = rv
+		changed = changed || c
+	}
+	return out, changed
+}
+
+// value returns v with redacted keys masked in any map or struct it contains
+// and detected strings scrubbed. Values holding nothing to change are
+// returned as they are; the others come back as map[string]any and []any,
+// with struct fields named as encoding/json would name them.
+func (r redactor) value(v any, depth int) (any, bool) {
+	if v == nil || depth > maxRedactDepth {
+		return v, false
+	}
+	switch t := v.(type) {
+	case string:
+		out := scrubText(t, r.m.detectors)
+		if r.m.public {
+			out = hideEncrypted(out)
+		}
+		return out, out != t
+	case map[string]any:
+		return r.entries(t, depth)
+	case []any:
+		return r.slice(t, depth)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return v, false
+		}
+		if out, changed := r.value(rv.Elem().Interface(), depth+1); changed {
+			return out, true
+		}
+	case reflect.String:
+		if out, changed := r.value(rv.String(), depth); changed {
+			return out, true
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v, false
+		}
+		entries := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			entries[iter.Key().String()] = iter.Value().Interface()
+		}
+		if out, changed := r.entries(entries, depth); changed {
+			return out, true
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v, false
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		if out, changed := r.slice(items, depth); changed {
+			return out, true
+		}
+	case reflect.Struct:
+		if out, changed := r.entries(structFields(rv), depth); changed {
+			return out, true
+		}
+	}
+	return v, false
+}
+
+// structFields returns the exported fields of a struct keyed by their JSON
+// names, skipping fields tagged "-".
+func structFields(rv reflect.Value) map[string]any {
+	rt := rv.Type()
+	fields := make(map[string]any, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[name] = rv.Field(i).Interface()
+	}
+	return fields
+}
@@ -0,0 +1,114 @@
+package morgana_test
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactionPolicy(t *testing.T) {
+	require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{
+		Keys:     morgana.CommonSecretKeys,
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`^x-internal-`)},
+	}))
+	defer func() { require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{})) }()
+
+	type credentials struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	m := morgana.New("DB").WithMessage("connect failed").
+		WithAddMetaDataKey("DB_Password", "sec-1").
+		WithAddMetaDataKey("x-internal-host", "sec-2").
+		WithAddMetaDataKey("conn", map[string]any{"host": "db", "Authorization": "sec-3"}).
+		WithAddMetaDataKey("attempts", []any{map[string]any{"api_key": "sec-4"}}).
+		WithAddMetaDataKey("creds", &credentials{User: "admin", Password: "sec-5"}).
+		WithAddMetaDataKey("headers", map[string][]string{"Cookie": {"sec-6"}}).
+		WithAnnotation(2, "retrying", map[string]any{"session_token": "sec-7"})
+
+	outputs := map[string]string{
+		"String":          m.String(),
+		"Error":           m.Error(),
+		"ToError":         m.ToError().Error(),
+		"%+v":             fmt.Sprintf("%+v", m),
+		"GetStringDetail": morgana.GetStringDetail(m.ToError()),
+		"ToJson":          m.ToJson(),
+		"ToJsonSafe":      m.ToJsonSafe(),
+		"ToFields":        fmt.Sprint(m.ToFields()),
+		"Problem":         fmt.Sprint(m.ToProblemDetails()),
+	}
+	var logs bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logs, nil)).Error("failed", "err", m)
+	outputs["slog"] = logs.String()
+
+	for name, out := range outputs {
+		assert.NotContains(t, out, "sec-", name)
+	}
+	assert.Contains(t, outputs["ToJson"], `"user":"admin"`)
+	assert.Contains(t, outputs["ToJson"], `"host":"db"`)
+	assert.Contains(t, outputs["ToJson"], `"DB_Password":"[REDACTED]"`)
+
+	assert.Contains(t, outputs["String"], `"Authorization":"[REDACTED]"`)
+	assert.Equal(t, "sec-1", m.GetMetaDataKey("DB_Password"), "stored values are left untouched")
+
+	t.Run("BadGlob", func(t *testing.T) {
+		assert.Error(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{Keys: []string{"[pass"}}))
+		assert.Equal(t, morgana.CommonSecretKeys, morgana.GetRedactionPolicy().Keys)
+	})
+}
+
+func TestRedactStrategies(t *testing.T) {
+	defer func() { require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{})) }()
+
+	hash := morgana.RedactHash([]byte("support-secret"))
+	require.NoError(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{
+		Keys: []string{"*token*"},
+		Strategies: []morgana.KeyStrategy{
+			{Key: "card*", Strategy: morgana.RedactMaskLast(4)},
+			{Key: "user_*", Strategy: hash},
+		},
+	}))
+
+	build := func(user string) morgana.Morgana {
+		return morgana.New("PAY").
+			WithAddMetaDataKey("card_number", "4111111111111111").
+			WithAddMetaDataKey("User_Email", user).
+			WithAddMetaDataKey("session_token", "tok-1")
+	}
+	first := build("jane@example.com").ToFields()["metaData"].(map[string]any)
+	second := build("jane@example.com").ToFields()["metaData"].(map[string]any)
+	other := build("john@example.com").ToFields()["metaData"].(map[string]any)
+
+	assert.Equal(t, "************1111", first["card_number"])
+	assert.Regexp(t, `^\[h:[0-9a-f]{6}\]$`, first["User_Email"])
+	assert.Equal(t, first["User_Email"], second["User_Email"])
+	assert.NotEqual(t, first["User_Email"], other["User_Email"])
+	assert.Equal(t, morgana.RedactedValue, first["session_token"])
+
+	otherSecret := morgana.RedactHash([]byte("rotated"))
+	assert.NotEqual(t, hash("jane@example.com"), otherSecret("jane@example.com"))
+
+	t.Run("DefaultStrategy", func(t *testing.T) {
+		morgana.SetDefaultRedactStrategy(morgana.RedactMaskLast(2))
+		defer morgana.SetDefaultRedactStrategy(nil)
+		assert.Len(t, morgana.GetRedactionPolicy().Strategies, 2)
+
+		m := build("jane@example.com").WithAddMetaDataKey("pin", 1234).WithRedactedKey("pin")
+		md := m.ToFields()["metaData"].(map[string]any)
+		assert.Equal(t, "***-1", md["session_token"])
+		assert.Equal(t, "**34", md["pin"])
+		assert.Equal(t, "************1111", md["card_number"])
+	})
+
+	t.Run("Masking", func(t *testing.T) {
+		assert.Equal(t, "***", morgana.RedactMaskLast(4)("abc"))
+		assert.Equal(t, "[REDACTED]", morgana.RedactHash(nil)("x"))
+		assert.Error(t, morgana.SetRedactionPolicy(morgana.RedactionPolicy{Strategies: []morgana.KeyStrategy{{Key: "x"}}}))
+	})
+}
@@ -0,0 +1,352 @@
+package morgana
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Renderer writes m in one media type. safe asks for the public
+// representation, as ToJsonSafe does. Redacted keys are masked either way.
+type Renderer func(w io.Writer, m Morgana, safe bool) error
+
+// HTTPOptions configures WriteHTTPRequest.
+type HTTPOptions struct {
+	// Safe selects the public output, without stack errors and causes.
+	Safe bool
+	// DefaultMediaType is written when the request has no Accept header or
+	// accepts none of the registered media types. Defaults to application/json.
+	DefaultMediaType string
+	// HTMLTemplate replaces the built-in text/html error page. It is executed
+	// with an HTMLErrorPage.
+	HTMLTemplate *template.Template
+}
+
+// HTMLErrorPage is the data an HTML error page template is executed with.
+type HTMLErrorPage struct {
+	Status      int
+	Title       string
+	Message     string
+	CustomCode  string
+	ID          string
+	FieldErrors []FieldError
+	// Details holds String() output for unsafe responses and is empty otherwise.
+	Details string
+	Morgana Morgana
+}
+
+var renderers = struct {
+	sync.RWMutex
+	byType map[string]Renderer
+	order  []string
+}{byType: make(map[string]Renderer)}
+
+// RegisterRenderer makes WriteHTTPRequest able to produce mediaType,
+// replacing any renderer already registered for it. Media types registered
+// earlier are preferred when an Accept range such as text/* matches several.
+func RegisterRenderer(mediaType string, r Renderer) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	renderers.Lock()
+	defer renderers.Unlock()
+	if _, ok := renderers.byType[mediaType]; !ok {
+		renderers.order = append(renderers.order, mediaType)
+	}
+	renderers.byType[mediaType] = r
+}
+
+func init() {
+	RegisterRenderer("application/json", renderJSON)
+	RegisterRenderer(ProblemJSONContentType, renderProblem)
+	RegisterRenderer("application/xml", renderXML)
+	RegisterRenderer("text/plain", renderText)
+	RegisterRenderer("text/html", defaultHTMLRenderer)
+	RegisterRenderer("text/xml", renderXML)
+}
+
+// WriteHTTPRequest writes the Morgana in the media type negotiated from the
+// request's Accept header.
+func (m *morgana) WriteHTTPRequest(w http.ResponseWriter, r *http.Request, opts HTTPOptions) {
+	if w == nil {
+		return
+	}
+	statusCode := m.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	defaultType := opts.DefaultMediaType
+	if defaultType == "" {
+		defaultType = "application/json"
+	}
+	accept := ""
+	if r != nil {
+		accept = r.Header.Get("Accept")
+	}
+
+	mediaType, render := negotiate(accept, defaultType)
+	if mediaType == "text/html" && opts.HTMLTemplate != nil {
+		render = htmlRenderer(opts.HTMLTemplate)
+	}
+
+	var view Morgana = m
+	if opts.Safe {
+		view = m.safeView(true)
+	}
+	var body bytes.Buffer
+	if render == nil || render(&body, view, opts.Safe) != nil {
+		mediaType = "application/json"
+		body.Reset()
+		_ = renderJSON(&body, view, opts.Safe)
+	}
+
+	contentType := mediaType
+	if strings.HasPrefix(mediaType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body.Bytes())
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+	order     int
+}
+
+// negotiate picks the registered media type best matching an Accept header,
+// falling back to defaultType.
+func negotiate(accept string, defaultType string) (string, Renderer) {
+	renderers.RLock()
+	defer renderers.RUnlock()
+
+	var ranges []acceptRange
+	for i, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, q: q, order: i})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		if si, sj := specificity(ranges[i].mediaType), specificity(ranges[j].mediaType); si != sj {
+			return si > sj
+		}
+		return ranges[i].order < ranges[j].order
+	})
+
+	for _, ar := range ranges {
+		switch {
+		case ar.mediaType == "*/*":
+			if r, ok := renderers.byType[defaultType]; ok {
+				return defaultType, r
+			}
+		case strings.HasSuffix(ar.mediaType, "/*"):
+			prefix := strings.TrimSuffix(ar.mediaType, "*")
+			if strings.HasPrefix(defaultType, prefix) {
+				if r, ok := renderers.byType[defaultType]; ok {
+					return defaultType, r
+				}
+			}
+			for _, mt := range renderers.order {
+				if strings.HasPrefix(mt, prefix) {
+					return mt, renderers.byType[mt]
+				}
+			}
+		default:
+			if r, ok := renderers.byType[ar.mediaType]; ok {
+				return ar.mediaType, r
+			}
+		}
+	}
+	return defaultType, renderers.byType[defaultType]
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func renderJSON(w io.Writer, m Morgana, safe bool) error {
+	if safe {
+		_, err := io.WriteString(w, signForHTTP(m.ToJsonSafe()))
+		return err
+	}
+	_, err := io.WriteString(w, signForHTTP(m.ToJson()))
+	return err
+}
+
+func renderProblem(w io.Writer, m Morgana, _ bool) error {
+	body, err := json.Marshal(m.ToProblemDetails())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, signForHTTP(string(body)))
+	return err
+}
+
+type xmlFrame struct {
+	File     string `xml:"file,attr,omitempty"`
+	Line     int    `xml:"line,attr,omitempty"`
+	Function string `xml:"function,attr,omitempty"`
+}
+
+type xmlFieldError struct {
+	Field string `xml:"field,attr"`
+	Code  string `xml:"code,attr,omitempty"`
+	Msg   string `xml:",chardata"`
+}
+
+type xmlEntry struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlMorgana struct {
+	XMLName     xml.Name        `xml:"error"`
+	ID          string          `xml:"id,omitempty"`
+	Type        string          `xml:"type,omitempty"`
+	With        string          `xml:"with,omitempty"`
+	Msg         string          `xml:"msg,omitempty"`
+	StatusCode  int             `xml:"statusCode,omitempty"`
+	CustomCode  string          `xml:"customCode,omitempty"`
+	StackTrace  string          `xml:"stackTrace,omitempty"`
+	StackFrames []xmlFrame      `xml:"stackFrames>frame,omitempty"`
+	MetaData    []xmlEntry      `xml:"metaData>entry,omitempty"`
+	FieldErrors []xmlFieldError `xml:"fieldErrors>fieldError,omitempty"`
+}
+
+func renderXML(w io.Writer, m Morgana, safe bool) error {
+	doc := xmlMorgana{
+		ID:         m.GetID(),
+		Type:       m.GetType(),
+		With:       m.GetWith(),
+		Msg:        m.GetMessage(),
+		StatusCode: m.GetStatusCode(),
+		CustomCode: m.GetCustomCode(),
+	}
+	if mm, ok := m.(*morgana); ok {
+		doc.StackTrace = mm.StackTrace
+	}
+	for _, f := range m.GetStackFrames() {
+		doc.StackFrames = append(doc.StackFrames, xmlFrame(f))
+	}
+	for _, fe := range m.GetFieldErrors() {
+		doc.FieldErrors = append(doc.FieldErrors, xmlFieldError(fe))
+	}
+	md := renderMetaData(m)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		doc.MetaData = append(doc.MetaData, xmlEntry{Key: k, Value: fmt.Sprint(md[k])})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
+
+func renderText(w io.Writer, m Morgana, safe bool) error {
+	if !safe {
+		_, err := io.WriteString(w, m.String())
+		return err
+	}
+	status := m.GetStatusCode()
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s\n", status, http.StatusText(status))
+	if msg := m.GetMessage(); msg != "" {
+		fmt.Fprintf(&b, "%s\n", msg)
+	}
+	if code := m.GetCustomCode(); code != "" {
+		fmt.Fprintf(&b, "code: %s\n", code)
+	}
+	for _, fe := range m.GetFieldErrors() {
+		fmt.Fprintf(&b, "%s: %s\n", fe.Field, fe.Msg)
+	}
+	if id := m.GetID(); id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// renderMetaData returns the metadata a renderer may show. Redacted keys are
+// masked in safe and unsafe output alike.
+func renderMetaData(m Morgana) map[string]any {
+	mm, ok := m.(*morgana)
+	if !ok {
+		return m.GetMetaData()
+	}
+	return mm.redactMap(mm.MetaData)
+}
+
+var defaultHTMLTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>{{.Status}} {{.Title}}</title></head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .FieldErrors}}<ul>{{range .FieldErrors}}<li><strong>{{.Field}}</strong>: {{.Msg}}</li>{{end}}</ul>{{end}}
+{{if .CustomCode}}<p>Code: <code>{{.CustomCode}}</code></p>{{end}}
+{{if .ID}}<p>Reference: <code>{{.ID}}</code></p>{{end}}
+{{if .Details}}<pre>{{.Details}}</pre>{{end}}
+</body>
+</html>
+`))
+
+var defaultHTMLRenderer = htmlRenderer(defaultHTMLTemplate)
+
+func htmlRenderer(tmpl *template.Template) Renderer {
+	return func(w io.Writer, m Morgana, safe bool) error {
+		status := m.GetStatusCode()
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		page := HTMLErrorPage{
+			Status:      status,
+			Title:       http.StatusText(status),
+			Message:     m.GetMessage(),
+			CustomCode:  m.GetCustomCode(),
+			ID:          m.GetID(),
+			FieldErrors: m.GetFieldErrors(),
+			Morgana:     m,
+		}
+		if !safe {
+			page.Details = m.String()
+		}
+		return tmpl.Execute(w, page)
+	}
+}
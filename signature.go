@@ -0,0 +1,335 @@
+package morgana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Signature algorithms.
+const (
+	SignatureHS256   = "HS256"
+	SignatureEd25519 = "Ed25519"
+)
+
+// MetaDataSignature holds the signature status of a Morgana decoded under
+// SignatureFlag or SignatureRequire: SignatureValid, SignatureMissing or
+// SignatureInvalid.
+const MetaDataSignature = "signature_status"
+
+// Signature statuses.
+const (
+	SignatureValid   = "valid"
+	SignatureMissing = "missing"
+	SignatureInvalid = "invalid"
+)
+
+var (
+	// ErrUnsigned reports a document without a signature.
+	ErrUnsigned = errors.New("morgana: document is not signed")
+	// ErrInvalidSignature reports a signature that does not match the
+	// document.
+	ErrInvalidSignature = errors.New("morgana: invalid document signature")
+)
+
+// Signature is the "signature" member of a signed document. Value is the
+// base64url signature of the canonical document.
+type Signature struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Value     string `json:"value"`
+}
+
+// Signer signs canonical documents.
+type Signer interface {
+	Sign(payload []byte) (Signature, error)
+}
+
+// Verifier checks signatures made by a Signer.
+type Verifier interface {
+	Verify(payload []byte, sig Signature) error
+}
+
+// HMACSigner signs and verifies with HMAC-SHA256 under a shared key.
+type HMACSigner struct {
+	keyID string
+	key   []byte
+}
+
+func NewHMACSigner(keyID string, key []byte) *HMACSigner {
+	return &HMACSigner{keyID: keyID, key: bytes.Clone(key)}
+}
+
+func (s *HMACSigner) Sign(payload []byte) (Signature, error) {
+	if len(s.key) == 0 {
+		return Signature{}, fmt.Errorf("morgana: HMAC key %q is empty", s.keyID)
+	}
+	return Signature{Algorithm: SignatureHS256, KeyID: s.keyID, Value: base64.RawURLEncoding.EncodeToString(s.mac(payload))}, nil
+}
+
+func (s *HMACSigner) Verify(payload []byte, sig Signature) error {
+	if sig.Algorithm != SignatureHS256 || sig.KeyID != s.keyID || len(s.key) == 0 {
+		return ErrInvalidSignature
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig.Value)
+	if err != nil || !hmac.Equal(got, s.mac(payload)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *HMACSigner) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Ed25519Signer signs with an Ed25519 private key and verifies with its
+// public key.
+type Ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{keyID: keyID, key: key}
+}
+
+func (s *Ed25519Signer) Sign(payload []byte) (Signature, error) {
+	if len(s.key) != ed25519.PrivateKeySize {
+		return Signature{}, fmt.Errorf("morgana: invalid Ed25519 key %q", s.keyID)
+	}
+	return Signature{Algorithm: SignatureEd25519, KeyID: s.keyID, Value: base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.key, payload))}, nil
+}
+
+func (s *Ed25519Signer) Verify(payload []byte, sig Signature) error {
+	if len(s.key) != ed25519.PrivateKeySize {
+		return ErrInvalidSignature
+	}
+	return NewEd25519Verifier(s.keyID, s.key.Public().(ed25519.PublicKey)).Verify(payload, sig)
+}
+
+// Ed25519Verifier verifies Ed25519 signatures, for receivers that hold only
+// the public key.
+type Ed25519Verifier struct {
+	keyID string
+	key   ed25519.PublicKey
+}
+
+func NewEd25519Verifier(keyID string, key ed25519.PublicKey) *Ed25519Verifier {
+	return &Ed25519Verifier{keyID: keyID, key: key}
+}
+
+func (v *Ed25519Verifier) Verify(payload []byte, sig Signature) error {
+	if sig.Algorithm != SignatureEd25519 || sig.KeyID != v.keyID || len(v.key) != ed25519.PublicKeySize {
+		return ErrInvalidSignature
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig.Value)
+	if err != nil || !ed25519.Verify(v.key, payload, got) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifierSet accepts a signature any of its verifiers accepts, so keys can
+// be rotated without rejecting documents signed with the previous one.
+type VerifierSet []Verifier
+
+func (vs VerifierSet) Verify(payload []byte, sig Signature) error {
+	for _, v := range vs {
+		if v != nil && v.Verify(payload, sig) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Sign returns the JSON document of m, as written by ToJson, signed by s.
+func Sign(m Morgana, s Signer) ([]byte, error) {
+	doc, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return SignJSON(doc, s)
+}
+
+// Verify checks the signature of a document written by Sign and decodes it.
+// The signature policy set with SetSignaturePolicy is not applied.
+func Verify(data []byte, v Verifier) (Morgana, error) {
+	if err := VerifyJSON(data, v); err != nil {
+		return nil, err
+	}
+	m := &morgana{}
+	if err := m.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SignJSON signs a JSON object, such as ToJson or ToJsonSafe output, and
+// returns it in canonical form with a "signature" member. A previous
+// signature is replaced.
+func SignJSON(doc []byte, s Signer) ([]byte, error) {
+	members, payload, err := canonicalDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := s.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	members["signature"] = sig
+	return encodeCanonical(members)
+}
+
+// VerifyJSON checks the "signature" member of a JSON object against the rest
+// of the document. It returns ErrUnsigned when there is none, and an error
+// wrapping ErrInvalidSignature when it does not match.
+func VerifyJSON(doc []byte, v Verifier) error {
+	members, payload, err := canonicalDocument(doc)
+	if err != nil {
+		return err
+	}
+	if members["signature"] == nil {
+		return ErrUnsigned
+	}
+	raw, err := json.Marshal(members["signature"])
+	if err != nil {
+		return err
+	}
+	var sig Signature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if v == nil {
+		return fmt.Errorf("%w: no verifier", ErrInvalidSignature)
+	}
+	if err := v.Verify(payload, sig); err != nil {
+		if errors.Is(err, ErrInvalidSignature) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// canonicalDocument decodes a JSON object and returns its members and the
+// canonical encoding of the members other than "signature": sorted keys,
+// numbers as written and no HTML escaping.
+func canonicalDocument(doc []byte) (map[string]any, []byte, error) {
+	v, err := decodeJSONValue(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	members, ok := v.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("morgana: signed document must be a JSON object")
+	}
+	sig := members["signature"]
+	delete(members, "signature")
+	payload, err := encodeCanonical(members)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sig != nil {
+		members["signature"] = sig
+	}
+	return members, payload, nil
+}
+
+func encodeCanonical(v any) ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// SignaturePolicy selects how FromJSON and FromHTTPResponse treat document
+// signatures.
+type SignaturePolicy int
+
+const (
+	// SignatureIgnore decodes documents without checking signatures. It is
+	// the default.
+	SignatureIgnore SignaturePolicy = iota
+	// SignatureFlag decodes every document and records the outcome of the
+	// check under MetaDataSignature.
+	SignatureFlag
+	// SignatureRequire rejects unsigned and invalid documents: FromJSON
+	// returns the error, and FromHTTPResponse returns the generic HTTP
+	// Morgana for the response status with the error as cause.
+	SignatureRequire
+)
+
+type signatureConfig struct {
+	policy   SignaturePolicy
+	verifier Verifier
+}
+
+var signaturePolicy atomic.Pointer[signatureConfig]
+
+// SetSignaturePolicy sets the process-wide signature policy and the verifier
+// it checks signatures with.
+func SetSignaturePolicy(policy SignaturePolicy, v Verifier) {
+	signaturePolicy.Store(&signatureConfig{policy: policy, verifier: v})
+}
+
+// checkSignature applies the signature policy to m, decoded from doc. A nil
+// doc stands for a document shape that carries no signature.
+func checkSignature(m Morgana, doc []byte) (Morgana, error) {
+	cfg := signaturePolicy.Load()
+	if cfg == nil || cfg.policy == SignatureIgnore {
+		return m, nil
+	}
+	err := ErrUnsigned
+	if doc != nil {
+		err = VerifyJSON(doc, cfg.verifier)
+	}
+	status := SignatureValid
+	switch {
+	case errors.Is(err, ErrUnsigned):
+		status = SignatureMissing
+	case err != nil:
+		status = SignatureInvalid
+	}
+	if cfg.policy == SignatureRequire && err != nil {
+		return nil, err
+	}
+	return m.WithAddMetaDataKey(MetaDataSignature, status), nil
+}
+
+var httpSigner atomic.Pointer[Signer]
+
+// SetHTTPSigner makes WriteHTTP, WriteHTTPProblem and WriteHTTPRequest for
+// application/json and application/problem+json sign the documents they
+// write. Nil stops signing.
+func SetHTTPSigner(s Signer) {
+	if s == nil {
+		httpSigner.Store(nil)
+		return
+	}
+	httpSigner.Store(&s)
+}
+
+// signForHTTP signs doc with the HTTP signer, if one is set. Documents that
+// cannot be signed are written unsigned.
+func signForHTTP(doc string) string {
+	s := httpSigner.Load()
+	if s == nil {
+		return doc
+	}
+	signed, err := SignJSON([]byte(doc), *s)
+	if err != nil {
+		return doc
+	}
+	return string(signed)
+}
@@ -0,0 +1,129 @@
+package morgana_test
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatures(t *testing.T) {
+	m := morgana.New("PAYMENT").WithCustomCode("DECLINED").WithStatusCode(http.StatusPaymentRequired).
+		WithAddMetaDataKey("amount", 1250).WithError(morgana.New("BANK").WithCustomCode("LIMIT"))
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signers := map[string]struct {
+		signer   morgana.Signer
+		verifier morgana.Verifier
+	}{
+		"HMAC":    {morgana.NewHMACSigner("h1", []byte("shared")), morgana.NewHMACSigner("h1", []byte("shared"))},
+		"Ed25519": {morgana.NewEd25519Signer("e1", priv), morgana.NewEd25519Verifier("e1", pub)},
+	}
+	for name, s := range signers {
+		t.Run(name, func(t *testing.T) {
+			signed, err := morgana.Sign(m, s.signer)
+			require.NoError(t, err)
+			assert.Contains(t, string(signed), `"signature":{`)
+
+			back, err := morgana.Verify(signed, s.verifier)
+			require.NoError(t, err)
+			assert.Equal(t, m.GetID(), back.GetID())
+			assert.Equal(t, "LIMIT", back.GetMorganaStackErrors()[0].GetCustomCode())
+
+			for _, forged := range []string{
+				strings.Replace(string(signed), `"statusCode":402`, `"statusCode":200`, 1),
+				strings.Replace(string(signed), `"LIMIT"`, `"OTHER"`, 1),
+				strings.Replace(string(signed), `"amount":1250`, `"amount":1`, 1),
+			} {
+				_, err := morgana.Verify([]byte(forged), s.verifier)
+				assert.ErrorIs(t, err, morgana.ErrInvalidSignature)
+			}
+			_, err = morgana.Verify([]byte(m.ToJson()), s.verifier)
+			assert.ErrorIs(t, err, morgana.ErrUnsigned)
+		})
+	}
+
+	t.Run("Rotation", func(t *testing.T) {
+		old, next := morgana.NewHMACSigner("k1", []byte("old")), morgana.NewHMACSigner("k2", []byte("new"))
+		signed, err := morgana.Sign(m, old)
+		require.NoError(t, err)
+		assert.NoError(t, morgana.VerifyJSON(signed, morgana.VerifierSet{next, old}))
+		assert.ErrorIs(t, morgana.VerifyJSON(signed, next), morgana.ErrInvalidSignature)
+	})
+}
+
+func TestSignaturePolicy(t *testing.T) {
+	signer := morgana.NewHMACSigner("k1", []byte("shared"))
+	defer morgana.SetSignaturePolicy(morgana.SignatureIgnore, nil)
+
+	m := morgana.New("AUTH").WithCustomCode("DENIED").WithStatusCode(http.StatusForbidden)
+	signed, err := morgana.Sign(m, signer)
+	require.NoError(t, err)
+	forged := []byte(strings.Replace(string(signed), `"DENIED"`, `"ALLOWED"`, 1))
+	unsigned := []byte(m.ToJson())
+
+	t.Run("Flag", func(t *testing.T) {
+		morgana.SetSignaturePolicy(morgana.SignatureFlag, signer)
+		for doc, status := range map[string]string{
+			string(signed):   morgana.SignatureValid,
+			string(forged):   morgana.SignatureInvalid,
+			string(unsigned): morgana.SignatureMissing,
+		} {
+			back, err := morgana.FromJSON([]byte(doc))
+			require.NoError(t, err)
+			assert.Equal(t, status, back.GetMetaDataKey(morgana.MetaDataSignature))
+		}
+	})
+
+	t.Run("Require", func(t *testing.T) {
+		morgana.SetSignaturePolicy(morgana.SignatureRequire, signer)
+		_, err := morgana.FromJSON(signed)
+		assert.NoError(t, err)
+		_, err = morgana.FromJSON(forged)
+		assert.ErrorIs(t, err, morgana.ErrInvalidSignature)
+		_, err = morgana.FromJSON(unsigned)
+		assert.ErrorIs(t, err, morgana.ErrUnsigned)
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		morgana.SetSignaturePolicy(morgana.SignatureRequire, signer)
+		morgana.SetHTTPSigner(signer)
+		defer morgana.SetHTTPSigner(nil)
+
+		rec := httptest.NewRecorder()
+		m.WriteHTTP(rec, true)
+		back := morgana.FromHTTPResponse(rec.Result())
+		assert.Equal(t, "DENIED", back.GetCustomCode())
+		assert.Equal(t, morgana.SignatureValid, back.GetMetaDataKey(morgana.MetaDataSignature))
+
+		tampered := httptest.NewRecorder()
+		tampered.Header().Set("Content-Type", "application/json")
+		tampered.WriteHeader(http.StatusForbidden)
+		_, _ = tampered.Body.WriteString(strings.Replace(rec.Body.String(), `"DENIED"`, `"ALLOWED"`, 1))
+		rejected := morgana.FromHTTPResponse(tampered.Result())
+		assert.Equal(t, "HTTP", rejected.GetType())
+		assert.Empty(t, rejected.GetCustomCode())
+		assert.True(t, errors.Is(rejected.Cause(), morgana.ErrInvalidSignature))
+
+		problem := httptest.NewRecorder()
+		m.WriteHTTPProblem(problem)
+		back = morgana.FromHTTPResponse(problem.Result())
+		assert.Equal(t, "DENIED", back.GetCustomCode())
+		assert.Equal(t, morgana.SignatureValid, back.GetMetaDataKey(morgana.MetaDataSignature))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", morgana.ProblemJSONContentType)
+		negotiated := httptest.NewRecorder()
+		m.WriteHTTPRequest(negotiated, req, morgana.HTTPOptions{Safe: true})
+		back = morgana.FromHTTPResponse(negotiated.Result())
+		assert.Equal(t, "DENIED", back.GetCustomCode())
+		assert.Equal(t, morgana.SignatureValid, back.GetMetaDataKey(morgana.MetaDataSignature))
+	})
+}
@@ -0,0 +1,173 @@
+package morgana
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+)
+
+// maxLogChainDepth bounds how many levels of stack errors LogValue expands.
+const maxLogChainDepth = 16
+
+// LogValue implements slog.LogValuer. The group holds the ToFields fields,
+// with metadata redacted, frames included and nested stack errors as
+// "stackErrors" sub-groups.
+func (m *morgana) LogValue() slog.Value {
+	return morganaLogValue(m, SlogOptions{Frames: true, MaxChainDepth: maxLogChainDepth}, make(map[Morgana]struct{}))
+}
+
+// SlogOptions configures a SlogHandler.
+type SlogOptions struct {
+	// Frames includes the stack trace, stack frames and annotation frames.
+	Frames bool
+	// MaxChainDepth is how many levels of nested stack errors are expanded;
+	// 0 logs only the error itself.
+	MaxChainDepth int
+}
+
+// SlogHandler wraps a slog.Handler and expands every attribute whose value
+// is an error carrying a Morgana (found with GetMorgana) into a group of
+// structured attributes.
+type SlogHandler struct {
+	next slog.Handler
+	opts SlogOptions
+}
+
+// NewSlogHandler wraps next. A nil opts logs no frames and no nested errors.
+func NewSlogHandler(next slog.Handler, opts *SlogOptions) *SlogHandler {
+	h := &SlogHandler{next: next}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.expand(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	expanded := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		expanded[i] = h.expand(a)
+	}
+	return &SlogHandler{next: h.next.WithAttrs(expanded), opts: h.opts}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	return &SlogHandler{next: h.next.WithGroup(name), opts: h.opts}
+}
+
+func (h *SlogHandler) expand(a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		expanded := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			expanded[i] = h.expand(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(expanded...)}
+	case slog.KindAny, slog.KindLogValuer:
+		if err, ok := a.Value.Any().(error); ok {
+			if m := GetMorgana(err); m != nil {
+				return slog.Attr{Key: a.Key, Value: morganaLogValue(m, h.opts, make(map[Morgana]struct{}))}
+			}
+		}
+	}
+	return a
+}
+
+func morganaLogValue(m Morgana, opts SlogOptions, seen map[Morgana]struct{}) slog.Value {
+	seen[m] = struct{}{}
+	defer delete(seen, m)
+
+	fields := m.ToFields()
+	if !opts.Frames {
+		delete(fields, "stackTrace")
+		delete(fields, "stackFrames")
+		if annotations, ok := fields["annotations"].([]Annotation); ok {
+			stripped := make([]Annotation, len(annotations))
+			for i, a := range annotations {
+				stripped[i] = Annotation{Msg: a.Msg, MetaData: a.MetaData}
+			}
+			fields["annotations"] = stripped
+		}
+	}
+	attrs := make([]slog.Attr, 0, len(fields)+1)
+	for _, k := range sortedKeys(fields) {
+		attrs = append(attrs, slog.Attr{Key: k, Value: fieldLogValue(fields[k])})
+	}
+
+	if opts.MaxChainDepth > 0 {
+		var nested []slog.Attr
+		next := opts
+		next.MaxChainDepth--
+		for i, stackError := range m.GetMorganaStackErrors() {
+			if _, cycle := seen[stackError]; cycle {
+				continue
+			}
+			nested = append(nested, slog.Attr{Key: strconv.Itoa(i), Value: morganaLogValue(stackError, next, seen)})
+		}
+		if len(nested) != 0 {
+			attrs = append(attrs, slog.Attr{Key: "stackErrors", Value: slog.GroupValue(nested...)})
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func fieldLogValue(v any) slog.Value {
+	switch t := v.(type) {
+	case map[string]any:
+		attrs := make([]slog.Attr, 0, len(t))
+		for _, k := range sortedKeys(t) {
+			attrs = append(attrs, slog.Attr{Key: k, Value: fieldLogValue(t[k])})
+		}
+		return slog.GroupValue(attrs...)
+	case []StackFrame:
+		attrs := make([]slog.Attr, len(t))
+		for i, f := range t {
+			attrs[i] = slog.Group(strconv.Itoa(i), "file", f.File, "line", f.Line, "function", f.Function)
+		}
+		return slog.GroupValue(attrs...)
+	case []FieldError:
+		attrs := make([]slog.Attr, len(t))
+		for i, fe := range t {
+			attrs[i] = slog.Group(strconv.Itoa(i), "field", fe.Field, "code", fe.Code, "msg", fe.Msg)
+		}
+		return slog.GroupValue(attrs...)
+	case []Annotation:
+		attrs := make([]slog.Attr, len(t))
+		for i, a := range t {
+			group := []any{"msg", a.Msg}
+			if a.Frame != (StackFrame{}) {
+				group = append(group, "file", a.Frame.File, "line", a.Frame.Line, "function", a.Frame.Function)
+			}
+			if len(a.MetaData) != 0 {
+				group = append(group, slog.Attr{Key: "metaData", Value: fieldLogValue(a.MetaData)})
+			}
+			attrs[i] = slog.Group(strconv.Itoa(i), group...)
+		}
+		return slog.GroupValue(attrs...)
+	default:
+		return slog.AnyValue(v)
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
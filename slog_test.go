@@ -0,0 +1,61 @@
+package morgana_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/bi0dread/morgana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLogLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	buf.Reset()
+	return line
+}
+
+func TestSlog(t *testing.T) {
+	inner := morgana.New("DB").WithCustomCode("DB_DOWN").WithMessage("db down")
+	m := morgana.New("API").WithCustomCode("FAILED").WithMessage("request failed").WithStackTrace(1).
+		WithAddMetaDataKey("token", "s3cr3t").WithRedactedKey("token").WithError(inner.ToError())
+
+	t.Run("LogValuer", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, nil))
+		logger.Error("failed", "err", m)
+
+		line := decodeLogLine(t, &buf)
+		errGroup := line["err"].(map[string]any)
+		assert.Equal(t, "FAILED", errGroup["customCode"])
+		assert.Equal(t, "[REDACTED]", errGroup["metaData"].(map[string]any)["token"])
+		assert.NotEmpty(t, errGroup["stackTrace"])
+		nested := errGroup["stackErrors"].(map[string]any)["0"].(map[string]any)
+		assert.Equal(t, "DB_DOWN", nested["customCode"])
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(morgana.NewSlogHandler(slog.NewJSONHandler(&buf, nil), &morgana.SlogOptions{MaxChainDepth: 1}))
+
+		logger.Error("failed", "err", fmt.Errorf("handler: %w", m.ToError()))
+		errGroup := decodeLogLine(t, &buf)["err"].(map[string]any)
+		assert.Equal(t, "FAILED", errGroup["customCode"])
+		assert.NotContains(t, errGroup, "stackTrace")
+		assert.Contains(t, errGroup, "stackErrors")
+
+		logger.With("req", slog.GroupValue(slog.Any("cause", m.ToError()))).Info("with attrs")
+		line := decodeLogLine(t, &buf)
+		assert.Equal(t, "FAILED", line["req"].(map[string]any)["cause"].(map[string]any)["customCode"])
+
+		plain := slog.New(morgana.NewSlogHandler(slog.NewJSONHandler(&buf, nil), nil))
+		plain.Error("failed", "err", m.ToError())
+		errGroup = decodeLogLine(t, &buf)["err"].(map[string]any)
+		assert.NotContains(t, errGroup, "stackErrors")
+	})
+}
@@ -0,0 +1,122 @@
+package morgana
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RedactStrategy returns what output shows in place of a redacted value.
+type RedactStrategy func(value any) string
+
+// KeyStrategy applies Strategy to the redacted keys matching the Key glob,
+// in path.Match syntax and case-insensitively. Keys matching it are redacted
+// even when no other rule names them.
+type KeyStrategy struct {
+	Key      string
+	Strategy RedactStrategy
+}
+
+// RedactPlaceholder writes RedactedValue. It is the default strategy.
+func RedactPlaceholder(any) string {
+	return RedactedValue
+}
+
+// RedactHash writes the first six hex digits of the HMAC-SHA256 of the value
+// under secret, such as "[h:3f9a1c]". Equal values give equal output, so
+// incidents can be correlated without showing the values. An empty secret
+// falls back to RedactPlaceholder.
+func RedactHash(secret []byte) RedactStrategy {
+	if len(secret) == 0 {
+		return RedactPlaceholder
+	}
+	secret = append([]byte(nil), secret...)
+	return func(value any) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(redactText(value)))
+		return "[h:" + hex.EncodeToString(mac.Sum(nil))[:6] + "]"
+	}
+}
+
+// RedactMaskLast keeps the last n characters of the value and masks the
+// others with '*', so "4111111111111111" becomes "************1111". Values
+// no longer than n are masked completely.
+func RedactMaskLast(n int) RedactStrategy {
+	return func(value any) string {
+		r := []rune(redactText(value))
+		keep := n
+		if keep < 0 || keep >= len(r) {
+			keep = 0
+		}
+		return strings.Repeat("*", len(r)-keep) + string(r[len(r)-keep:])
+	}
+}
+
+// Replacement lets a detector use the strategy, for example
+// RegisterDetector(EmailDetector, RedactHash(secret).Replacement()).
+func (s RedactStrategy) Replacement() Replacement {
+	return func(match string) string { return s(match) }
+}
+
+// redactText is the text a strategy works on: strings as they are, other
+// values as JSON.
+func redactText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if b, err := json.Marshal(value); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// SetDefaultRedactStrategy sets the strategy used for redacted keys that no
+// KeyStrategy matches. Nil restores RedactPlaceholder.
+func SetDefaultRedactStrategy(s RedactStrategy) {
+	for {
+		old := redactionPolicy.Load()
+		next := &RedactionPolicy{}
+		if old != nil {
+			*next = *old
+		}
+		next.Default = s
+		if redactionPolicy.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// strategyFor returns the strategy of the first KeyStrategy matching key.
+func (p *RedactionPolicy) strategyFor(key string) RedactStrategy {
+	if p == nil {
+		return nil
+	}
+	lower := strings.ToLower(key)
+	for _, ks := range p.Strategies {
+		if ok, _ := path.Match(ks.Key, lower); ok {
+			return ks.Strategy
+		}
+	}
+	return nil
+}
+
+// redactedValue returns what output shows for the redacted value v under key.
+func redactedValue(key string, v any) string {
+	p := redactionPolicy.Load()
+	if s := p.strategyFor(key); s != nil {
+		return s(v)
+	}
+	if p != nil && p.Default != nil {
+		return p.Default(v)
+	}
+	return RedactedValue
+}
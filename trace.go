@@ -0,0 +1,264 @@
+package morgana
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Metadata keys set by WithTrace from the typed context keys.
+const (
+	MetaDataRequestID     = "request_id"
+	MetaDataTraceID       = "trace_id"
+	MetaDataSpanID        = "span_id"
+	MetaDataTraceState    = "trace_state"
+	MetaDataCorrelationID = "correlation_id"
+)
+
+// Headers read and written by CorrelationMiddleware.
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// maxRequestIDLength bounds request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceIDKey
+	spanIDKey
+	traceStateKey
+	correlationIDKey
+	fieldsKey
+)
+
+var traceKeys = []struct {
+	key      contextKey
+	metaData string
+}{
+	{requestIDKey, MetaDataRequestID},
+	{traceIDKey, MetaDataTraceID},
+	{spanIDKey, MetaDataSpanID},
+	{traceStateKey, MetaDataTraceState},
+	{correlationIDKey, MetaDataCorrelationID},
+}
+
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, requestIDKey)
+}
+
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey, id)
+}
+
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, traceIDKey)
+}
+
+func ContextWithSpanID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, spanIDKey, id)
+}
+
+func SpanIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, spanIDKey)
+}
+
+func ContextWithTraceState(ctx context.Context, state string) context.Context {
+	return context.WithValue(ctx, traceStateKey, state)
+}
+
+func TraceStateFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, traceStateKey)
+}
+
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	return stringFromContext(ctx, correlationIDKey)
+}
+
+func stringFromContext(ctx context.Context, key contextKey) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	v, ok := ctx.Value(key).(string)
+	return v, ok && v != ""
+}
+
+// ContextExtractor returns metadata to attach to a Morgana built with ctx.
+type ContextExtractor func(ctx context.Context) map[string]any
+
+// Extractor is a named ContextExtractor registered with RegisterExtractor.
+// Extractors run after the built-in typed keys in ascending Priority order
+// (registration order for equal priorities), so on a key conflict the value
+// of the higher priority extractor wins. Keys listed in RedactKeys are marked
+// with WithRedactedKey whenever the extractor returns them.
+type Extractor struct {
+	Name       string
+	Priority   int
+	Extract    ContextExtractor
+	RedactKeys []string
+}
+
+var contextExtractors struct {
+	sync.RWMutex
+	list []Extractor
+}
+
+// RegisterExtractor adds e to the extractors WithTrace runs. Names must be
+// unique among named extractors.
+func RegisterExtractor(e Extractor) error {
+	if e.Extract == nil {
+		return fmt.Errorf("morgana: extractor %q has no Extract function", e.Name)
+	}
+	contextExtractors.Lock()
+	defer contextExtractors.Unlock()
+	if e.Name != "" {
+		for _, registered := range contextExtractors.list {
+			if registered.Name == e.Name {
+				return fmt.Errorf("morgana: extractor %q is already registered", e.Name)
+			}
+		}
+	}
+	e.RedactKeys = slices.Clone(e.RedactKeys)
+	list := append(slices.Clone(contextExtractors.list), e)
+	slices.SortStableFunc(list, func(a, b Extractor) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+	contextExtractors.list = list
+	return nil
+}
+
+// UnregisterExtractor removes the extractor registered under name and
+// reports whether there was one.
+func UnregisterExtractor(name string) bool {
+	contextExtractors.Lock()
+	defer contextExtractors.Unlock()
+	i := slices.IndexFunc(contextExtractors.list, func(e Extractor) bool { return e.Name == name })
+	if name == "" || i < 0 {
+		return false
+	}
+	contextExtractors.list = slices.Delete(slices.Clone(contextExtractors.list), i, i+1)
+	return true
+}
+
+// RegisterContextExtractor adds an unnamed extractor with priority 0.
+func RegisterContextExtractor(fn ContextExtractor) {
+	if fn == nil {
+		return
+	}
+	_ = RegisterExtractor(Extractor{Extract: fn})
+}
+
+// traceMetaData collects the typed context values and extractor output, and
+// the keys the extractors asked to redact.
+func traceMetaData(ctx context.Context) (map[string]any, []string) {
+	md := make(map[string]any)
+	for _, tk := range traceKeys {
+		if v, ok := stringFromContext(ctx, tk.key); ok {
+			md[tk.metaData] = v
+		}
+	}
+
+	contextExtractors.RLock()
+	extractors := contextExtractors.list
+	contextExtractors.RUnlock()
+	var redact []string
+	for _, e := range extractors {
+		values := e.Extract(ctx)
+		for k, v := range values {
+			md[k] = v
+		}
+		for _, k := range e.RedactKeys {
+			if _, ok := values[k]; ok {
+				redact = append(redact, k)
+			}
+		}
+	}
+	return md, redact
+}
+
+// CorrelationMiddleware puts the request ID and W3C trace context of each
+// request into its context for WithTrace. The request ID is read from the
+// X-Request-ID header, or generated, and echoed in the response; the
+// traceparent and tracestate headers provide the trace ID, parent span ID
+// and trace state.
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx = ContextWithRequestID(ctx, id)
+
+		if traceID, spanID, ok := ParseTraceParent(r.Header.Get(TraceParentHeader)); ok {
+			ctx = ContextWithSpanID(ContextWithTraceID(ctx, traceID), spanID)
+			if state := strings.Join(r.Header.Values(TraceStateHeader), ","); state != "" {
+				ctx = ContextWithTraceState(ctx, state)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTraceParent returns the trace ID and parent span ID of a W3C
+// traceparent header value.
+func ParseTraceParent(header string) (traceID string, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", "", false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,136 @@
+package morgana
+
+import "net/http"
+
+// WrapStrategy picks the Morgana whose identity (Type, With, Msg, StatusCode
+// and CustomCode) a wrap keeps when both errors carry one.
+type WrapStrategy func(inner, outer Morgana) Morgana
+
+// WrapInnerWins keeps the identity of the wrapped error. It is the strategy
+// used by Wrap.
+func WrapInnerWins(inner, outer Morgana) Morgana {
+	return inner
+}
+
+// WrapOuterWins keeps the identity of the wrapping error.
+func WrapOuterWins(inner, outer Morgana) Morgana {
+	return outer
+}
+
+// WrapMostSevere keeps the identity with the higher status code, counting a
+// missing status as 500. Ties go to the outer error.
+func WrapMostSevere(inner, outer Morgana) Morgana {
+	if severity(inner) > severity(outer) {
+		return inner
+	}
+	return outer
+}
+
+func severity(m Morgana) int {
+	if code := m.GetStatusCode(); code != 0 {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// Wrap wraps inner with outer using WrapInnerWins. See WrapWith.
+func Wrap(inner error, outer error) error {
+	return WrapWith(WrapInnerWins, inner, outer)
+}
+
+// WrapWith wraps inner with outer without modifying either of them.
+//
+// When one of the errors is nil the other is returned. When neither carries a
+// Morgana the result reads "outer: inner" and unwraps to both. Otherwise the
+// result is a copy of the Morgana chosen by strategy, or of the only Morgana
+// present; identity fields it leaves empty are taken from the other Morgana
+// and metadata keys it lacks are copied, together with their redaction, from
+// the other Morgana. The other error is attached with WithError, and the
+// result unwraps to both original errors, so errors.Is and errors.As reach
+// every link of either chain while GetMorgana yields the merged copy.
+func WrapWith(strategy WrapStrategy, inner error, outer error) error {
+	if inner == nil {
+		return outer
+	}
+	if outer == nil {
+		return inner
+	}
+
+	innerMorgana := GetMorgana(inner)
+	outerMorgana := GetMorgana(outer)
+	if innerMorgana == nil && outerMorgana == nil {
+		return &wrapError{inner: inner, outer: outer}
+	}
+
+	winner, other, otherErr := innerMorgana, outerMorgana, outer
+	switch {
+	case innerMorgana == nil:
+		winner, other, otherErr = outerMorgana, nil, inner
+	case outerMorgana != nil:
+		if strategy == nil {
+			strategy = WrapInnerWins
+		}
+		if strategy(innerMorgana, outerMorgana) == outerMorgana {
+			winner, other, otherErr = outerMorgana, innerMorgana, inner
+		}
+	}
+
+	merged := winner.DeepClone()
+	if other != nil {
+		if merged.GetType() == "" {
+			merged = merged.WithType(other.GetType())
+		}
+		if merged.GetWith() == "" {
+			merged = merged.With(other.GetWith())
+		}
+		if merged.GetMessage() == "" {
+			merged = merged.WithMessage(other.GetMessage())
+		}
+		if merged.GetStatusCode() == 0 {
+			merged = merged.WithStatusCode(other.GetStatusCode())
+		}
+		if merged.GetCustomCode() == "" {
+			merged = merged.WithCustomCode(other.GetCustomCode())
+		}
+		for k, v := range other.GetMetaData() {
+			if !merged.HasMetaDataKey(k) {
+				merged = merged.WithAddMetaDataKey(k, deepCopyValue(v))
+			}
+		}
+		if o := morganaOf(other); o != nil {
+			for k := range o.redactedKeys {
+				merged = merged.WithRedactedKey(k)
+			}
+		}
+	}
+	return &wrapError{inner: inner, outer: outer, m: merged.WithError(otherErr)}
+}
+
+// wrapError joins two errors. m is the merged Morgana, or nil when neither
+// error carries one.
+type wrapError struct {
+	inner error
+	outer error
+	m     Morgana
+}
+
+func (e *wrapError) Error() string {
+	if e.m != nil {
+		return e.m.Error()
+	}
+	return e.outer.Error() + ": " + e.inner.Error()
+}
+
+func (e *wrapError) Unwrap() []error {
+	return []error{e.outer, e.inner}
+}
+
+// As hands out the merged Morgana before errors.As descends into the
+// original errors.
+func (e *wrapError) As(target any) bool {
+	if t, ok := target.(*Morgana); ok && e.m != nil {
+		*t = e.m
+		return true
+	}
+	return false
+}